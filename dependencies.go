@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BlockerSeparator separates the ids of blocking tasks in the taskline
+// metadata.
+const BlockerSeparator = "+"
+
+// Blockers returns the indexes of the tasks in the tasklist which still block
+// a task.
+func (tl *Tasklist) Blockers(task Task) (indexes []int) {
+	for _, hash := range task.blockedBy {
+		// Identical tasks share a hash, but never block themselves.
+		if hash == task.hash {
+			continue
+		}
+
+		if index, ok := tl.FindHash(hash); ok {
+			indexes = append(indexes, index)
+		}
+	}
+
+	return
+}
+
+// IsBlocked reports whether any task blocking the task is still active.
+func IsBlocked(task Task) bool {
+	return len(MainList.Blockers(task)) != 0
+}
+
+// setDependencies makes the tasks selected by the first part of a
+// SELECT/SELECT argument blocked by the tasks selected by the second part.
+// Using "-" as the second part removes every dependency of the tasks.
+func setDependencies(cmd string) {
+	ListManager.EnsureInitialized(MainList)
+	exitOnEmptyTasks("Depends")
+
+	var parts []string
+	recursiveSplit(cmd, &parts)
+
+	if len(parts) != 2 {
+		Error(ErrInvalidSelector, "Depends", cmd)
+	}
+
+	indexes, err := MainList.SelectTasks(parts[0])
+
+	ExitOnSelectorError(err, "Depends", parts[0])
+
+	var blockers []int
+
+	if parts[1] != "-" {
+		blockers, err = MainList.SelectTasks(parts[1])
+
+		ExitOnSelectorError(err, "Depends", parts[1])
+	}
+
+	for _, i := range append(append([]int{}, indexes...), blockers...) {
+		if _, ok := MainList.tasks[i]; !ok {
+			Error(ErrInvalidIndex, "Depends", i)
+		}
+	}
+
+	for _, i := range indexes {
+		task := MainList.tasks[i]
+
+		if blockers == nil {
+			task.blockedBy = nil
+		}
+
+		for _, b := range blockers {
+			hash := MainList.tasks[b].hash
+
+			if b == i || containsString(task.blockedBy, hash) {
+				continue
+			}
+
+			task.blockedBy = append(task.blockedBy, hash)
+		}
+
+		MainList.tasks[i] = task
+		ListManager.Record(JournalEdit, task)
+	}
+
+	MainList.MarkModified()
+}
+
+// warnBlocked warns about tasks which are finished while tasks blocking them
+// are still active. Blockers finished at the same time are ignored.
+func warnBlocked(indexes []int) {
+	finishing := make(map[int]bool)
+
+	for _, i := range indexes {
+		finishing[i] = true
+	}
+
+	for _, i := range indexes {
+		for _, b := range MainList.Blockers(MainList.tasks[i]) {
+			if !finishing[b] {
+				Warn("Finish: \"%s\" is still blocked by \"%s\"", MainList.tasks[i].text, MainList.tasks[b].text)
+			}
+		}
+	}
+}
+
+// containsString reports whether a slice contains a string.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GraphParams holds the command line arguments for the `graph` command.
+type GraphParams struct{}
+
+// Execute prints the active tasks and their dependencies as a Graphviz DOT
+// graph. Edges point from blocking tasks to the tasks they block; blocked
+// tasks are drawn dashed.
+func (a *GraphParams) Execute(args []string) error {
+	ListManager.EnsureInitialized(MainList)
+
+	fmt.Println("digraph tasks {")
+	fmt.Println("\trankdir=LR;")
+	fmt.Println("\tnode [shape=box];")
+
+	keys := MainList.OrderKeys()
+
+	for n, index := range keys {
+		task := MainList.tasks[index]
+		style := ""
+
+		if IsBlocked(task) {
+			style = ", style=dashed"
+		}
+
+		fmt.Printf("\tt%d [label=%s%s];\n", n+1, dotString(fmt.Sprintf("%d - %s", n+1, task.text)), style)
+	}
+
+	for n, index := range keys {
+		for _, b := range MainList.Blockers(MainList.tasks[index]) {
+			fmt.Printf("\tt%d -> t%d;\n", displayIndex(keys, b), n+1)
+		}
+	}
+
+	fmt.Println("}")
+
+	return nil
+}
+
+// dotString quotes a string for use in a DOT graph.
+func dotString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// init gets called when the package is imported; adds the command to the
+// global argument parser.
+func init() {
+	GlobalParser.AddCommand("graph", "Print task dependencies as a Graphviz DOT graph", "", &GraphParams{})
+}
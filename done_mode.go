@@ -1,11 +1,15 @@
 package main
 
+import "strings"
+
 // DoneActions contains all actions for finished task management.
 type DoneActions struct {
 	Restore    func(string) `short:"r" long:"restore" description:"Restore a task to the main tasklist" value-name:"SELECT"`
 	RestoreAll func()       `short:"a" long:"restore-all" description:"Restores all finished tasks"`
 	Delete     func(string) `short:"d" long:"delete" description:"Remove a finished task from the list" value-name:"SELECT"`
 	Wipe       func()       `short:"w" long:"wipe" description:"Remove all tasks"`
+
+	Filters ListFilters `group:"Filter Options"`
 }
 
 var doneActions DoneActions
@@ -15,26 +19,42 @@ var doneActions DoneActions
 func (a *DoneActions) Execute(args []string) error {
 	ListManager.EnsureInitialized(DoneList)
 
-	DoneList.Show(OutputOptions.Format)
+	// Active subtasks are counted towards the progress of their parent.
+	if strings.Contains(OutputOptions.Format, "{progress}") {
+		ListManager.EnsureInitialized(MainList)
+	}
+
+	DoneList.Show(OutputOptions.Format, ShowSnoozed, a.Filters.TaskFilters()...)
 
 	ListManager.Save()
 
 	return nil
 }
 
+// restore moves tasks back to the main tasklist along with their subtasks.
+// Finished parents are restored as well, so the tasks keep their place in the
+// hierarchy.
 func restore(selector string) {
 	ListManager.EnsureInitialized(DoneList)
 	exitOnEmptyDone("Restore")
 	ListManager.EnsureInitialized(MainList)
 
-	indexes, err := DoneList.SelectTasks(selector)
+	selected, err := DoneList.SelectTasks(selector)
+
+	ExitOnSelectorError(err, "Restore", selector)
+
+	var indexes []int
 
-	if err != nil {
-		Error(ErrInvalidSelector, "Restore", selector)
+	for _, i := range selected {
+		indexes = append(indexes, DoneList.Ancestors(i)...)
+		indexes = append(indexes, DoneList.Subtrees([]int{i})...)
 	}
 
+	indexes = uniqueIndexes(indexes)
+
 	for _, i := range indexes {
 		MainList.Add(DoneList.tasks[i])
+		ListManager.Record(JournalRestore, DoneList.tasks[i])
 	}
 
 	DoneList.Remove(indexes)
@@ -53,8 +73,12 @@ func deleteDone(selector string) {
 
 	indexes, err := DoneList.SelectTasks(selector)
 
-	if err != nil {
-		Error(ErrInvalidSelector, "Delete", selector)
+	ExitOnSelectorError(err, "Delete", selector)
+
+	indexes = DoneList.Subtrees(indexes)
+
+	for _, i := range indexes {
+		ListManager.Record(JournalDeleteDone, DoneList.tasks[i])
 	}
 
 	DoneList.Remove(indexes)
@@ -64,6 +88,10 @@ func wipeDone() {
 	ListManager.EnsureInitialized(DoneList)
 	exitOnEmptyDone("Wipe")
 
+	for _, task := range DoneList.tasks {
+		ListManager.Record(JournalDeleteDone, task)
+	}
+
 	DoneList.tasks = make(map[int]Task)
 
 	DoneList.MarkModified()
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+)
+
+// EncryptionAES256GCM is the only supported encryption scheme. The key is
+// derived from the secret using PBKDF2-HMAC-SHA256.
+const EncryptionAES256GCM = "aes-256-gcm"
+
+// KeyDerivationIterations is the PBKDF2 iteration count used for new
+// uploads. The count is stored in every payload, so it can be raised later.
+const KeyDerivationIterations = 200000
+
+// DefaultKeyEnv is the environment variable holding the passphrase if no key
+// source is configured.
+const DefaultKeyEnv = "TX_SYNC_PASSPHRASE"
+
+// EncryptedPayload is uploaded in place of the tasklist when encryption is
+// enabled. The Sync service only ever sees this opaque object.
+type EncryptedPayload struct {
+	Encryption string `json:"encryption"`
+	Iterations int    `json:"iterations"`
+	Salt       string `json:"salt"`
+	Nonce      string `json:"nonce"`
+	Data       string `json:"data"`
+}
+
+// ReadEncryptionSecret resolves a key source (see ReadSecret) to the secret it
+// holds. Defaults to the DefaultKeyEnv environment variable.
+func ReadEncryptionSecret(keySource string) []byte {
+	if keySource == "" {
+		keySource = SecretEnv + DefaultKeyEnv
+	}
+
+	secret, err := ReadSecret(keySource)
+
+	if err != nil {
+		Error(ErrEncryptionKey, keySource, err)
+	}
+
+	return []byte(secret)
+}
+
+// EncryptPayload encrypts a serialized tasklist using a key derived from the
+// secret and returns the JSON encoded EncryptedPayload.
+func EncryptPayload(plaintext []byte, secret []byte) ([]byte, error) {
+	salt := make([]byte, 16)
+
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(secret, salt, KeyDerivationIterations)
+
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(EncryptedPayload{
+		Encryption: EncryptionAES256GCM,
+		Iterations: KeyDerivationIterations,
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Data:       base64.StdEncoding.EncodeToString(gcm.Seal(nil, nonce, plaintext, nil)),
+	})
+}
+
+// DecryptPayload decrypts a JSON encoded EncryptedPayload. Authentication
+// fails if the secret is wrong or the payload was tampered with.
+func DecryptPayload(data []byte, secret []byte) ([]byte, error) {
+	var payload EncryptedPayload
+
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+
+	if payload.Encryption != EncryptionAES256GCM {
+		return nil, fmt.Errorf("unsupported encryption \"%s\"", payload.Encryption)
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(payload.Salt)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt: %v", err)
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(payload.Nonce)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid nonce: %v", err)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(payload.Data)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid data: %v", err)
+	}
+
+	gcm, err := newGCM(secret, salt, payload.Iterations)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce length")
+	}
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("wrong key or corrupted data")
+	}
+
+	return plaintext, nil
+}
+
+// IsEncryptedPayload reports whether the data downloaded from a Sync service
+// is an EncryptedPayload.
+func IsEncryptedPayload(data []byte) bool {
+	var payload struct {
+		Encryption string `json:"encryption"`
+	}
+
+	return json.Unmarshal(data, &payload) == nil && payload.Encryption != ""
+}
+
+func newGCM(secret []byte, salt []byte, iterations int) (cipher.AEAD, error) {
+	if iterations < 1 {
+		return nil, fmt.Errorf("invalid iteration count %d", iterations)
+	}
+
+	block, err := aes.NewCipher(pbkdf2SHA256(secret, salt, iterations, 32))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2SHA256 derives a key from a password as described in RFC 8018,
+// using HMAC-SHA256 as the pseudorandom function.
+func pbkdf2SHA256(password []byte, salt []byte, iterations int, keyLength int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLength := prf.Size()
+	blocks := (keyLength + hashLength - 1) / hashLength
+
+	var (
+		key     []byte
+		counter [4]byte
+	)
+
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(counter[:], uint32(block))
+		prf.Write(counter[:])
+
+		u := prf.Sum(nil)
+		t := append([]byte{}, u...)
+
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+
+		key = append(key, t...)
+	}
+
+	return key[:keyLength]
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/hex"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPBKDF2(t *testing.T) {
+	// Test vectors from RFC 7914, section 11.
+	key := pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 64)
+
+	AssertEqual(t, hex.EncodeToString(key), "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783", "Derived key does not match the test vector")
+}
+
+func TestEncryptPayload(t *testing.T) {
+	plaintext := []byte(`{"contents": "secret task"}`)
+
+	encrypted, err := EncryptPayload(plaintext, []byte("correct horse"))
+	AssertEqual(t, err, nil, "Could not encrypt payload")
+
+	if strings.Contains(string(encrypted), "secret task") {
+		t.Fatal("Encrypted payload contains the plaintext")
+	}
+
+	AssertEqual(t, IsEncryptedPayload(encrypted), true, "Encrypted payload is not recognized")
+	AssertEqual(t, IsEncryptedPayload(plaintext), false, "Plaintext payload is recognized as encrypted")
+
+	decrypted, err := DecryptPayload(encrypted, []byte("correct horse"))
+	AssertEqual(t, err, nil, "Could not decrypt payload")
+	AssertEqual(t, string(decrypted), string(plaintext), "Decrypted payload does not match the plaintext")
+
+	_, err = DecryptPayload(encrypted, []byte("battery staple"))
+	AssertNotEqual(t, err, nil, "Payload was decrypted with a wrong key")
+}
+
+func TestEncryptedSyncing(t *testing.T) {
+	vs := &versionedStorage{}
+	ts := httptest.NewServer(vs)
+	defer ts.Close()
+
+	os.Setenv("TX_TESTING_KEY", "correct horse")
+
+	InitTestingPathVariables(t)
+	MainList = &Tasklist{}
+	DoneList = &Tasklist{}
+
+	params := EnableParams{URL: ts.URL, Encrypt: true, KeyEnv: "TX_TESTING_KEY"}
+	params.Args.SyncID = "testing-sync-id"
+	params.Execute([]string{})
+
+	MainList = &Tasklist{}
+	DoneList = &Tasklist{}
+	ListManager = &TasklistManager{}
+	ListManager.EnsureInitialized(MainList)
+
+	AssertEqual(t, ListManager.source, Network, "Encrypted tasklist was not loaded")
+
+	add("secret task")
+	ListManager.Save()
+
+	t.Run("opaque", func(t *testing.T) {
+		if strings.Contains(vs.body, "secret task") {
+			t.Fatal("Sync service received the plaintext tasklist")
+		}
+	})
+
+	t.Run("downgrade", func(t *testing.T) {
+		encrypted := vs.body
+		vs.setContents("planted task\n", "")
+
+		AssertExitError(t, "TestEncryptedSyncing/downgrade", ErrDecryptPayload, func() {
+			MainList = &Tasklist{}
+			DoneList = &Tasklist{}
+			ListManager = &TasklistManager{}
+			ListManager.EnsureInitialized(MainList)
+		})
+
+		vs.body = encrypted
+	})
+
+	t.Run("wrong_key", func(t *testing.T) {
+		AssertExitError(t, "TestEncryptedSyncing/wrong_key", ErrDecryptPayload, func() {
+			os.Setenv("TX_TESTING_KEY", "battery staple")
+
+			MainList = &Tasklist{}
+			DoneList = &Tasklist{}
+			ListManager = &TasklistManager{}
+			ListManager.EnsureInitialized(MainList)
+		})
+	})
+}
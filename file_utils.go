@@ -1,9 +1,9 @@
 package main
 
 import (
-	"bufio"
+	"io"
 	"os"
-	"regexp"
+	"path/filepath"
 )
 
 func openFile(filePath string, errorCode int, optional bool) *os.File {
@@ -30,69 +30,73 @@ func createFile(filePath string, errorCode int) *os.File {
 	return file
 }
 
-// OpenTaskfile opens a taskfile for reading and handles errors.
-func OpenTaskfile(filePath string, optional bool) (taskfile *os.File) {
-	return openFile(filePath, ErrTaskfileOpen, optional)
-}
-
-// CreateTaskfile opens a taskfile for writing and handles errors.
-func CreateTaskfile(filePath string) (taskfile *os.File) {
-	return createFile(filePath, ErrTaskfileOpen)
-}
-
-// OpenSyncfile opens a syncfile for reading and handles errors.
-func OpenSyncfile(optional bool) (syncfile *os.File) {
-	return openFile(SyncfilePath, ErrSyncfileOpen, optional)
-}
-
-// CreateSyncfile opens a syncfile for writing and handles errors.
-func CreateSyncfile() (syncfile *os.File) {
-	return createFile(SyncfilePath, ErrSyncfileOpen)
-}
-
-// ReplaceOrAppendSyncfileLine essentialy makes sure that an "entry" appears in a
-// syncfile exactly once.
-func ReplaceOrAppendSyncfileLine(pattern *regexp.Regexp, repl string) {
-	// Read Syncfile contents into an array, skipping lines that match the
-	// pattern.
-	syncfile, err := os.OpenFile(SyncfilePath, os.O_CREATE|os.O_RDWR, 0644)
+// writeFileAtomic replaces a file by writing to a temporary file in the same
+// directory and renaming it, so the file is never left half-written.
+func writeFileAtomic(filePath string, data []byte, errorCode int) {
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".tx-")
 
 	if err != nil {
-		Error(ErrSyncfileOpen, SyncfilePath, err)
+		Error(errorCode, filePath, err)
 	}
 
-	defer syncfile.Close()
+	defer os.Remove(tmp.Name())
 
-	var contents []byte
+	_, err = tmp.Write(data)
 
-	scanner := bufio.NewScanner(syncfile)
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
 
-	for scanner.Scan() {
-		line := scanner.Text()
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
 
-		if !pattern.MatchString(line) {
-			contents = append(contents, line+"\n"...)
-		}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filePath)
 	}
 
-	if err := scanner.Err(); err != nil {
-		Error(ErrSyncfileRead, SyncfilePath, err)
+	if err != nil {
+		Error(errorCode, filePath, err)
 	}
+}
 
-	// Add the replacement to the end of the list and write the array back into
-	// the file.
-	contents = append(contents, repl...)
+// BackupTaskfile copies a taskfile to its backup file, unless backups are
+// disabled. Returns false if there was nothing to back up.
+func BackupTaskfile(filePath string) bool {
+	if ConfigOptions.Reckless {
+		return false
+	}
 
-	err = syncfile.Truncate(0)
+	src := OpenTaskfile(filePath, true)
 
-	if err != nil {
-		Error(ErrSyncfileWrite, SyncfilePath, err)
+	if src == nil {
+		return false
 	}
 
-	syncfile.Seek(0, 0)
-	_, err = syncfile.Write(contents)
+	defer src.Close()
 
-	if err != nil {
-		Error(ErrSyncfileWrite, SyncfilePath, err)
+	backupFilePath := GetMetafilePath(".bak", filePath)
+	dst := CreateTaskfile(backupFilePath)
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		Error(ErrBackupWrite, backupFilePath, err)
 	}
+
+	return true
+}
+
+// OpenTaskfile opens a taskfile for reading and handles errors.
+func OpenTaskfile(filePath string, optional bool) (taskfile *os.File) {
+	return openFile(filePath, ErrTaskfileOpen, optional)
+}
+
+// CreateTaskfile opens a taskfile for writing and handles errors.
+func CreateTaskfile(filePath string) (taskfile *os.File) {
+	return createFile(filePath, ErrTaskfileOpen)
+}
+
+// OpenJournalfile opens a journalfile for reading and handles errors.
+func OpenJournalfile(optional bool) (journalfile *os.File) {
+	return openFile(JournalfilePath, ErrJournalfileOpen, optional)
 }
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// GitTasklistFile is the name of the file holding the tasklist in every commit
+// made by GitBackend.
+const GitTasklistFile = "tasklist.json"
+
+// GitBackend is a SyncBackend which stores every tasklist on its own branch
+// ("tx/{syncID}") of a git repository. Every upload is a new commit, so the
+// full history of a tasklist is kept. The commit hash is used as entity tag.
+type GitBackend struct {
+	repo string
+	auth SyncAuth
+	env  []string
+}
+
+// NewGitBackend creates a GitBackend for a Sync URL like
+// "git+file:///path/repo.git". The "git+" prefix is stripped and the rest is
+// passed to git as the repository URL. Credentials are sent as extra HTTP
+// headers, which only affects git+http and git+https URLs.
+func NewGitBackend(syncURL string, auth SyncAuth) SyncBackend {
+	repo := syncURL[len("git+"):]
+
+	return &GitBackend{repo: strings.TrimSuffix(repo, "/"), auth: auth}
+}
+
+// Create generates a new random Sync ID. The branch itself is created by the
+// first upload.
+func (b *GitBackend) Create() (syncID string, err error) {
+	if _, err := b.git("", nil, "ls-remote", b.repo); err != nil {
+		return "", NewSyncError(ErrRequestSyncID, b.repo, err)
+	}
+
+	buf := make([]byte, 8)
+
+	if _, err := rand.Read(buf); err != nil {
+		return "", NewSyncError(ErrRequestSyncID, b.repo, err)
+	}
+
+	return fmt.Sprintf("%x", buf), nil
+}
+
+// Fetch reads the tasklist from the tip of the Sync ID's branch.
+func (b *GitBackend) Fetch(syncID string) (data []byte, etag string, err error) {
+	head, err := b.head(syncID)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	if head == "" {
+		return nil, "", NewSyncError(ErrNoSyncID, syncID)
+	}
+
+	dir, err := b.scratchRepo(syncID)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer os.RemoveAll(dir)
+
+	contents, err := b.git(dir, nil, "show", head+":"+GitTasklistFile)
+
+	if err != nil {
+		return nil, "", NewSyncError(ErrGitCommand, b.repo, err)
+	}
+
+	return []byte(contents), head, nil
+}
+
+// Store commits the tasklist on top of the Sync ID's branch and pushes it.
+// The push is rejected if the branch has moved in the meantime.
+func (b *GitBackend) Store(syncID string, data []byte, etag string) (newETag string, err error) {
+	head, err := b.head(syncID)
+
+	if err != nil {
+		return "", err
+	}
+
+	if etag != "" && head != etag {
+		return "", errPreconditionFailed
+	}
+
+	dir, err := b.scratchRepo(syncID)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer os.RemoveAll(dir)
+
+	blob, err := b.git(dir, data, "hash-object", "-w", "--stdin")
+
+	if err != nil {
+		return "", NewSyncError(ErrGitCommand, b.repo, err)
+	}
+
+	tree, err := b.git(dir, []byte("100644 blob "+blob+"\t"+GitTasklistFile+"\n"), "mktree")
+
+	if err != nil {
+		return "", NewSyncError(ErrGitCommand, b.repo, err)
+	}
+
+	hostname, _ := os.Hostname()
+	commitArgs := []string{"commit-tree", tree, "-m", "Update tasklist from " + hostname}
+
+	if head != "" {
+		commitArgs = append(commitArgs, "-p", head)
+	}
+
+	commit, err := b.git(dir, nil, commitArgs...)
+
+	if err != nil {
+		return "", NewSyncError(ErrGitCommand, b.repo, err)
+	}
+
+	ref := b.branch(syncID)
+	lease := fmt.Sprintf("--force-with-lease=%s:%s", ref, head)
+
+	// The porcelain output flags rejected refs with "!" whatever the locale.
+	output, err := b.git(dir, nil, "push", "--porcelain", lease, b.repo, commit+":"+ref)
+
+	if err != nil {
+		if etag != "" && pushRejected(output) {
+			return "", errPreconditionFailed
+		}
+
+		return "", NewSyncError(ErrGitCommand, b.repo, err)
+	}
+
+	return commit, nil
+}
+
+// Delete removes the Sync ID's branch.
+func (b *GitBackend) Delete(syncID string) error {
+	head, err := b.head(syncID)
+
+	if err != nil {
+		return err
+	}
+
+	if head == "" {
+		return NewSyncError(ErrNoSyncID, syncID)
+	}
+
+	// Pushing needs a repository to push from, which must not be the one the
+	// user happens to be in.
+	dir, err := b.scratchRepo(syncID)
+
+	if err != nil {
+		return err
+	}
+
+	defer os.RemoveAll(dir)
+
+	if _, err := b.git(dir, nil, "push", "--quiet", b.repo, "--delete", b.branch(syncID)); err != nil {
+		return NewSyncError(ErrGitCommand, b.repo, err)
+	}
+
+	return nil
+}
+
+// branch returns the full name of the branch storing a Sync ID's tasklist.
+func (b *GitBackend) branch(syncID string) string {
+	return "refs/heads/tx/" + syncID
+}
+
+// head returns the commit the Sync ID's branch points to, or an empty string
+// if the branch does not exist.
+func (b *GitBackend) head(syncID string) (string, error) {
+	out, err := b.git("", nil, "ls-remote", b.repo, b.branch(syncID))
+
+	if err != nil {
+		return "", NewSyncError(ErrGitCommand, b.repo, err)
+	}
+
+	fields := strings.Fields(out)
+
+	if len(fields) == 0 {
+		return "", nil
+	}
+
+	return fields[0], nil
+}
+
+// scratchRepo creates a temporary bare repository holding the Sync ID's branch
+// (if any) for running plumbing commands. The caller removes it.
+func (b *GitBackend) scratchRepo(syncID string) (string, error) {
+	dir, err := os.MkdirTemp("", "tx-git-")
+
+	if err != nil {
+		return "", NewSyncError(ErrGitCommand, b.repo, err)
+	}
+
+	steps := [][]string{
+		{"init", "--quiet", "--bare"},
+		{"fetch", "--quiet", b.repo, "+" + b.branch(syncID) + ":" + b.branch(syncID)},
+	}
+
+	// A missing branch is fine when uploading for the first time.
+	for i, args := range steps {
+		if _, err := b.git(dir, nil, args...); err != nil && i == 0 {
+			os.RemoveAll(dir)
+			return "", NewSyncError(ErrGitCommand, b.repo, err)
+		}
+	}
+
+	// Commits need an identity; fall back to a generic one if the user has
+	// not configured one.
+	if name, _ := b.git(dir, nil, "config", "user.name"); name == "" {
+		b.git(dir, nil, "config", "user.name", "tx")
+	}
+
+	if email, _ := b.git(dir, nil, "config", "user.email"); email == "" {
+		b.git(dir, nil, "config", "user.email", "tx@localhost")
+	}
+
+	return dir, nil
+}
+
+// pushRejected reports whether the porcelain output of git push lists a
+// rejected ref.
+func pushRejected(output string) bool {
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "!") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// git runs a git command in the provided directory and returns its output
+// without the trailing newline. The output is also returned if the command
+// fails.
+func (b *GitBackend) git(dir string, stdin []byte, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	if b.env == nil {
+		header, err := b.auth.HTTPHeaders()
+
+		// Missing credentials are fatal either way.
+		ExitOnSyncError(err, b.repo)
+
+		b.env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+		// Pass the headers as configuration through the environment, so
+		// secrets do not show up in the process list.
+		var count int
+
+		for name, values := range header {
+			for _, value := range values {
+				b.env = append(b.env,
+					fmt.Sprintf("GIT_CONFIG_KEY_%d=http.extraHeader", count),
+					fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s: %s", count, name, value),
+				)
+				count++
+			}
+		}
+
+		b.env = append(b.env, fmt.Sprintf("GIT_CONFIG_COUNT=%d", count))
+	}
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = b.env
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return strings.TrimSuffix(stdout.String(), "\n"), fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSuffix(stdout.String(), "\n"), nil
+}
+
+// init gets called when the package is imported; registers the backend for
+// git Sync URLs.
+func init() {
+	for _, transport := range []string{"file", "ssh", "http", "https"} {
+		RegisterSyncBackend("git+"+transport, NewGitBackend)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// InitTestingGitRepo creates an empty bare repository and returns its Sync
+// URL. Skips the current test if git is not installed.
+func InitTestingGitRepo(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	repo := t.TempDir() + "/repo.git"
+
+	if err := exec.Command("git", "init", "--quiet", "--bare", repo).Run(); err != nil {
+		t.Fatalf("Could not create bare repository: %v", err)
+	}
+
+	return "git+file://" + repo
+}
+
+func TestGitBackend(t *testing.T) {
+	syncURL := InitTestingGitRepo(t)
+	backend := NewSyncBackend(syncURL+"/", SyncAuth{})
+
+	syncID, err := backend.Create()
+	AssertEqual(t, err, nil, "Could not create Sync ID")
+
+	_, _, err = backend.Fetch(syncID)
+
+	var syncErr *SyncError
+	if !errors.As(err, &syncErr) || syncErr.Code != ErrNoSyncID {
+		t.Fatalf("Fetching a new Sync ID should fail with ErrNoSyncID, got %v", err)
+	}
+
+	first, err := backend.Store(syncID, []byte(`{"contents": "a"}`), "")
+	AssertEqual(t, err, nil, "Could not store tasklist")
+
+	second, err := backend.Store(syncID, []byte(`{"contents": "b"}`), first)
+	AssertEqual(t, err, nil, "Could not store tasklist conditionally")
+
+	_, err = backend.Store(syncID, []byte(`{"contents": "c"}`), first)
+	AssertEqual(t, err, errPreconditionFailed, "Outdated entity tag was accepted")
+
+	data, etag, err := backend.Fetch(syncID)
+	AssertEqual(t, err, nil, "Could not fetch tasklist")
+	AssertEqual(t, string(data), `{"contents": "b"}`, "Fetched tasklist does not match the stored one")
+	AssertEqual(t, etag, second, "Entity tag is not the latest commit")
+
+	// Every upload is kept in the history of the branch.
+	repo := strings.TrimPrefix(syncURL, "git+file://")
+	count, _ := exec.Command("git", "--git-dir", repo, "rev-list", "--count", "tx/"+syncID).Output()
+	AssertEqual(t, strings.TrimSpace(string(count)), "2", "Branch does not contain every upload")
+
+	AssertEqual(t, backend.Delete(syncID), nil, "Could not delete tasklist")
+
+	if err := backend.Delete(syncID); !errors.As(err, &syncErr) || syncErr.Code != ErrNoSyncID {
+		t.Fatalf("Deleting a missing Sync ID should fail with ErrNoSyncID, got %v", err)
+	}
+}
+
+func TestPushRejected(t *testing.T) {
+	rejected := "To /srv/tasks.git\n!\t3fa9:refs/heads/tx/id\t[rejected] (stale info)\nDone"
+	AssertEqual(t, pushRejected(rejected), true, "Rejected ref was not detected")
+
+	// The reason is translated in other locales, the flag is not.
+	translated := "To /srv/tasks.git\n!\t3fa9:refs/heads/tx/id\t[abgelehnt] (veraltete Info)\nDone"
+	AssertEqual(t, pushRejected(translated), true, "Rejected ref was not detected in another locale")
+
+	pushed := "To /srv/tasks.git\n \t3fa9:refs/heads/tx/id\tc01d..3fa9\nDone"
+	AssertEqual(t, pushRejected(pushed), false, "Pushed ref was reported as rejected")
+}
+
+func TestGitSyncing(t *testing.T) {
+	syncURL := InitTestingGitRepo(t)
+
+	InitTestingPathVariables(t)
+	MainList = &Tasklist{}
+	DoneList = &Tasklist{}
+
+	params := EnableParams{URL: syncURL}
+	params.Execute([]string{})
+
+	MainList = &Tasklist{}
+	DoneList = &Tasklist{}
+	ListManager = &TasklistManager{}
+	ListManager.EnsureInitialized(MainList)
+
+	AssertEqual(t, ListManager.source, Network, "Tasklist was not loaded from the repository")
+
+	add("versioned")
+	ListManager.Save()
+
+	data, _, _ := ListManager.backend.Fetch(ListManager.syncID)
+
+	if !strings.Contains(string(data), "versioned") {
+		t.Fatal("Tasklist was not pushed to the repository")
+	}
+}
@@ -16,11 +16,13 @@ var ConfigOptions struct {
 	Reckless        bool   `short:"R" long:"reckless" description:"Disable taking local backups after modifying a taskfile"`
 	Quiet           bool   `short:"Q" long:"quiet" description:"Disable the printing of warning messages"`
 	FallbackSyncURL string `short:"U" long:"fallback-sync-url" description:"The URL of the Sync service to use if no explicit URL is specified for the tasklist." value-name:"URL"`
+	OnConflict      string `long:"on-conflict" description:"Which version to keep when a task was changed differently both locally and on the Sync service" choice:"local" choice:"remote"`
 }
 
 // OutputOptions holds all the options which modify the output.
 var OutputOptions struct {
-	Format string `short:"o" long:"format" description:"Defines the output format.\nPlaceholders: {index}, {task}, {creationTime}, {creationDate}, {finishedTime}, {finishedDate}" value-name:"STRING"`
+	Format string `short:"o" long:"format" description:"Defines the output format.\nPlaceholders: {index}, {id}, {task}, {tags}, {priority}, {dueDate}, {dueTime}, {dueIn}, {waitDate}, {depth}, {progress}, {recurrence}, {note}, {hasNote}, {blockedBy}, {elapsed}, {running}, {attr:KEY}, {creationTime}, {creationDate}, {finishedTime}, {finishedDate}" value-name:"STRING"`
+	Sort   string `long:"sort" description:"The order tasks are listed in. Indexes are kept regardless of the order." choice:"index" choice:"priority" choice:"due"`
 }
 
 // RunCallback executes the configured callback command (if any).
@@ -49,7 +51,9 @@ func init() {
 	// Set defaults manually so they are available before parsing finishes.
 	ConfigOptions.List = "tasks"
 	ConfigOptions.FallbackSyncURL = ""
+	ConfigOptions.OnConflict = string(KeepLocal)
 	OutputOptions.Format = "{index} - {task}"
+	OutputOptions.Sort = SortIndex
 
 	GlobalParser.AddGroup("Configuration Options", "", &ConfigOptions)
 	GlobalParser.AddGroup("Output Options", "", &OutputOptions)
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults used for settings specified neither as a global option nor in the
+// syncfile.
+const (
+	DefaultTimeout        = 30 * time.Second
+	DefaultConnectTimeout = 10 * time.Second
+	DefaultRetries        = 3
+)
+
+// MaxRetryDelay caps the time waited between two attempts, including delays
+// requested by the server using Retry-After.
+const MaxRetryDelay = time.Minute
+
+// RetryBaseDelay is the delay before the first retry, doubled for every
+// further attempt.
+var RetryBaseDelay = 500 * time.Millisecond
+
+// NetworkOptions holds the global options configuring the HTTP client used
+// for syncing. Unset options fall back to the syncfile, then to the defaults.
+var NetworkOptions struct {
+	Timeout        time.Duration `long:"timeout" description:"The time limit for a single HTTP request to the Sync service" value-name:"DURATION" default-mask:"30s"`
+	ConnectTimeout time.Duration `long:"connect-timeout" description:"The time limit for connecting to the Sync service" value-name:"DURATION" default-mask:"10s"`
+	Retries        int           `long:"retries" description:"How many times failed requests are retried" value-name:"N" default-mask:"3"`
+	Proxy          string        `long:"proxy" description:"The proxy used for connecting to the Sync service. Defaults to the HTTP(S)_PROXY environment variables." value-name:"URL"`
+	CABundle       string        `long:"ca-bundle" description:"A PEM file with additional certificate authorities to trust" value-name:"PATH"`
+}
+
+// NetworkSettings configures the HTTP client used for syncing.
+type NetworkSettings struct {
+	Timeout        time.Duration
+	ConnectTimeout time.Duration
+	Retries        *int // nil if unset, as 0 disables retries.
+	Proxy          string
+	CABundle       string
+}
+
+// ParseLine extracts a network setting from a syncfile line and reports
+// whether the line held one.
+func (s *NetworkSettings) ParseLine(line string) bool {
+	if m := ConnectTimeoutPattern.FindStringSubmatch(line); len(m) == 2 {
+		s.ConnectTimeout = parseSyncfileDuration("connectTimeout", m[1])
+		return true
+	}
+
+	if m := TimeoutPattern.FindStringSubmatch(line); len(m) == 2 {
+		s.Timeout = parseSyncfileDuration("timeout", m[1])
+		return true
+	}
+
+	if m := RetriesPattern.FindStringSubmatch(line); len(m) == 2 {
+		retries, err := strconv.Atoi(strings.TrimSpace(m[1]))
+
+		if err != nil || retries < 0 {
+			Warn("Invalid retries in syncfile \"%s\": %s", SyncfilePath, m[1])
+			retries = 0
+		}
+
+		s.Retries = &retries
+		return true
+	}
+
+	if m := ProxyPattern.FindStringSubmatch(line); len(m) == 2 {
+		s.Proxy = strings.TrimSpace(m[1])
+		return true
+	}
+
+	if m := CABundlePattern.FindStringSubmatch(line); len(m) == 2 {
+		s.CABundle = strings.TrimSpace(m[1])
+		return true
+	}
+
+	return false
+}
+
+// networkSettingsJSON is the representation of NetworkSettings in the
+// syncfile, with durations written like "30s".
+type networkSettingsJSON struct {
+	Timeout        string `json:"timeout,omitempty"`
+	ConnectTimeout string `json:"connectTimeout,omitempty"`
+	Retries        *int   `json:"retries,omitempty"`
+	Proxy          string `json:"proxy,omitempty"`
+	CABundle       string `json:"caBundle,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s NetworkSettings) MarshalJSON() ([]byte, error) {
+	data := networkSettingsJSON{
+		Retries:  s.Retries,
+		Proxy:    s.Proxy,
+		CABundle: s.CABundle,
+	}
+
+	if s.Timeout > 0 {
+		data.Timeout = s.Timeout.String()
+	}
+
+	if s.ConnectTimeout > 0 {
+		data.ConnectTimeout = s.ConnectTimeout.String()
+	}
+
+	return json.Marshal(data)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Invalid values are ignored with
+// a warning, like in legacy syncfiles.
+func (s *NetworkSettings) UnmarshalJSON(jsonData []byte) error {
+	var data networkSettingsJSON
+
+	if err := json.Unmarshal(jsonData, &data); err != nil {
+		return err
+	}
+
+	*s = NetworkSettings{Proxy: data.Proxy, CABundle: data.CABundle}
+
+	if data.Timeout != "" {
+		s.Timeout = parseSyncfileDuration("timeout", data.Timeout)
+	}
+
+	if data.ConnectTimeout != "" {
+		s.ConnectTimeout = parseSyncfileDuration("connectTimeout", data.ConnectTimeout)
+	}
+
+	if data.Retries != nil {
+		retries := max(*data.Retries, 0)
+		s.Retries = &retries
+	}
+
+	return nil
+}
+
+func parseSyncfileDuration(key string, value string) time.Duration {
+	duration, err := time.ParseDuration(strings.TrimSpace(value))
+
+	if err != nil || duration < 0 {
+		Warn("Invalid %s in syncfile \"%s\": %s", key, SyncfilePath, value)
+		return 0
+	}
+
+	return duration
+}
+
+// withOptions returns the settings with the global options applied on top
+// and unset values replaced by their defaults.
+func (s NetworkSettings) withOptions() NetworkSettings {
+	if NetworkOptions.Timeout > 0 {
+		s.Timeout = NetworkOptions.Timeout
+	}
+
+	if NetworkOptions.ConnectTimeout > 0 {
+		s.ConnectTimeout = NetworkOptions.ConnectTimeout
+	}
+
+	if NetworkOptions.Retries >= 0 {
+		s.Retries = &NetworkOptions.Retries
+	}
+
+	if NetworkOptions.Proxy != "" {
+		s.Proxy = NetworkOptions.Proxy
+	}
+
+	if NetworkOptions.CABundle != "" {
+		s.CABundle = NetworkOptions.CABundle
+	}
+
+	if s.Timeout == 0 {
+		s.Timeout = DefaultTimeout
+	}
+
+	if s.ConnectTimeout == 0 {
+		s.ConnectTimeout = DefaultConnectTimeout
+	}
+
+	if s.Retries == nil {
+		retries := DefaultRetries
+		s.Retries = &retries
+	}
+
+	return s
+}
+
+// SyncHTTPClient is the HTTP client shared by all HTTP sync requests. It
+// retries failed requests with exponential backoff.
+type SyncHTTPClient struct {
+	client  *http.Client
+	retries int
+}
+
+// syncClient is the client returned by SyncClient, replaced by
+// ConfigureSyncClient. syncClientErr is set instead if the settings are
+// invalid.
+var (
+	syncClient    *SyncHTTPClient
+	syncClientErr error
+)
+
+// ConfigureSyncClient replaces the shared client with one using the provided
+// settings, which are typically parsed from the syncfile. Invalid settings
+// are reported by SyncClient, like any other failed sync request.
+func ConfigureSyncClient(settings NetworkSettings) {
+	syncClient, syncClientErr = NewSyncHTTPClient(settings.withOptions())
+}
+
+// SyncClient returns the shared client, creating it from the global options
+// if it has not been configured yet.
+func SyncClient() (*SyncHTTPClient, error) {
+	if syncClient == nil && syncClientErr == nil {
+		ConfigureSyncClient(NetworkSettings{})
+	}
+
+	return syncClient, syncClientErr
+}
+
+// NewSyncHTTPClient creates a client with the provided (complete) settings.
+// An invalid proxy URL or CA bundle is returned as a SyncError.
+func NewSyncHTTPClient(settings NetworkSettings) (*SyncHTTPClient, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	transport.DialContext = (&net.Dialer{
+		Timeout:   settings.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = settings.ConnectTimeout
+
+	if settings.Proxy != "" {
+		proxyURL, err := url.Parse(settings.Proxy)
+
+		if err != nil || proxyURL.Host == "" {
+			return nil, NewSyncError(ErrProxyURL, settings.Proxy)
+		}
+
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if settings.CABundle != "" {
+		pem, err := os.ReadFile(settings.CABundle)
+
+		if err != nil {
+			return nil, NewSyncError(ErrCABundle, settings.CABundle, err)
+		}
+
+		pool, err := x509.SystemCertPool()
+
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, NewSyncError(ErrCABundle, settings.CABundle, "no certificates found")
+		}
+
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &SyncHTTPClient{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   settings.Timeout,
+		},
+		retries: *settings.Retries,
+	}, nil
+}
+
+// Do sends a request, retrying it on 429 Too Many Requests and, if the
+// request is idempotent, on network errors and 5xx responses. The delay
+// between attempts doubles every time, unless the server requests a specific
+// one using Retry-After.
+func (c *SyncHTTPClient) Do(request *http.Request) (*http.Response, error) {
+	delay := RetryBaseDelay
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && request.GetBody != nil {
+			body, err := request.GetBody()
+
+			if err != nil {
+				return nil, err
+			}
+
+			request.Body = body
+		}
+
+		resp, err := c.client.Do(request)
+
+		if attempt >= c.retries || !retryable(request, resp, err) {
+			return resp, err
+		}
+
+		wait := delay
+
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				wait = retryAfter
+			}
+
+			resp.Body.Close()
+		}
+
+		time.Sleep(min(wait, MaxRetryDelay))
+
+		delay *= 2
+	}
+}
+
+// retryable reports whether a failed attempt should be repeated.
+func retryable(request *http.Request, resp *http.Response, err error) bool {
+	if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+
+	switch request.Method {
+	case "GET", "HEAD", "PUT", "DELETE":
+	default:
+		return false
+	}
+
+	return err != nil || resp.StatusCode >= 500
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is either
+// a number of seconds or an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+
+	return 0, false
+}
+
+func init() {
+	// Set defaults manually so they are available before parsing finishes.
+	// A negative value marks the option as unset.
+	NetworkOptions.Retries = -1
+
+	GlobalParser.AddGroup("Network Options", "", &NetworkOptions)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flakyHandler fails the first n requests with the provided status.
+func flakyHandler(n int, status int, header http.Header, requests *int) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		*requests++
+
+		if *requests <= n {
+			for name, values := range header {
+				w.Header()[name] = values
+			}
+
+			w.WriteHeader(status)
+			return
+		}
+
+		w.Write([]byte(`{"contents": "", "doneContents": ""}`))
+	}
+}
+
+func TestSyncHTTPClient(t *testing.T) {
+	retries := 3
+	settings := NetworkSettings{Timeout: time.Second, ConnectTimeout: time.Second, Retries: &retries}
+
+	t.Run("retry_5xx", func(t *testing.T) {
+		var requests int
+
+		ts := httptest.NewServer(flakyHandler(2, http.StatusBadGateway, nil, &requests))
+		defer ts.Close()
+
+		syncClient, _ = NewSyncHTTPClient(settings)
+
+		_, _, err := NewJSONBlobBackend(ts.URL, SyncAuth{}).Fetch("id")
+
+		AssertEqual(t, err, nil, "Request was not retried")
+		AssertEqual(t, requests, 3, "Unexpected number of requests")
+	})
+
+	t.Run("give_up", func(t *testing.T) {
+		var requests int
+
+		ts := httptest.NewServer(flakyHandler(10, http.StatusServiceUnavailable, nil, &requests))
+		defer ts.Close()
+
+		syncClient, _ = NewSyncHTTPClient(settings)
+
+		_, _, err := NewJSONBlobBackend(ts.URL, SyncAuth{}).Fetch("id")
+
+		AssertNotEqual(t, err, nil, "Failing request did not return an error")
+		AssertEqual(t, requests, 4, "Unexpected number of requests")
+	})
+
+	t.Run("no_retry_post", func(t *testing.T) {
+		var requests int
+
+		ts := httptest.NewServer(flakyHandler(1, http.StatusInternalServerError, nil, &requests))
+		defer ts.Close()
+
+		syncClient, _ = NewSyncHTTPClient(settings)
+
+		_, err := NewJSONBlobBackend(ts.URL, SyncAuth{}).Create()
+
+		AssertNotEqual(t, err, nil, "Failed POST request did not return an error")
+		AssertEqual(t, requests, 1, "POST request was retried")
+	})
+
+	t.Run("retry_after", func(t *testing.T) {
+		var requests int
+
+		ts := httptest.NewServer(flakyHandler(1, http.StatusTooManyRequests, http.Header{"Retry-After": {"1"}}, &requests))
+		defer ts.Close()
+
+		syncClient, _ = NewSyncHTTPClient(settings)
+
+		start := time.Now()
+		_, _, err := NewJSONBlobBackend(ts.URL, SyncAuth{}).Fetch("id")
+
+		AssertEqual(t, err, nil, "Rate limited request was not retried")
+
+		if time.Since(start) < time.Second {
+			t.Fatalf("Retry-After was not honored, retried after %v", time.Since(start))
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+		}))
+		defer ts.Close()
+
+		none := 0
+		syncClient, _ = NewSyncHTTPClient(NetworkSettings{Timeout: 50 * time.Millisecond, ConnectTimeout: time.Second, Retries: &none})
+
+		_, _, err := NewJSONBlobBackend(ts.URL, SyncAuth{}).Fetch("id")
+
+		AssertNotEqual(t, err, nil, "Slow request did not time out")
+	})
+
+	t.Run("proxy", func(t *testing.T) {
+		var proxied string
+
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			proxied = req.URL.String()
+			w.Write([]byte(`{"contents": "", "doneContents": ""}`))
+		}))
+		defer proxy.Close()
+
+		proxySettings := settings
+		proxySettings.Proxy = proxy.URL
+		syncClient, _ = NewSyncHTTPClient(proxySettings)
+
+		_, _, err := NewJSONBlobBackend("http://tx.invalid/", SyncAuth{}).Fetch("id")
+
+		AssertEqual(t, err, nil, "Request through proxy failed")
+		AssertEqual(t, proxied, "http://tx.invalid/id", "Request was not sent through the proxy")
+	})
+
+	t.Run("ca_bundle", func(t *testing.T) {
+		ts := httptest.NewTLSServer(flakyHandler(0, 0, nil, new(int)))
+		defer ts.Close()
+
+		syncClient, _ = NewSyncHTTPClient(settings)
+
+		_, _, err := NewJSONBlobBackend(ts.URL, SyncAuth{}).Fetch("id")
+
+		if err == nil || !strings.Contains(err.Error(), "certificate") {
+			t.Fatalf("Untrusted certificate was accepted: %v", err)
+		}
+
+		bundle := filepath.Join(t.TempDir(), "ca.pem")
+		os.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0644)
+
+		bundleSettings := settings
+		bundleSettings.CABundle = bundle
+		syncClient, _ = NewSyncHTTPClient(bundleSettings)
+
+		_, _, err = NewJSONBlobBackend(ts.URL, SyncAuth{}).Fetch("id")
+
+		AssertEqual(t, err, nil, "Certificate from CA bundle was not trusted")
+	})
+
+	t.Run("invalid_settings", func(t *testing.T) {
+		for _, invalid := range []struct {
+			settings NetworkSettings
+			code     int
+		}{
+			{NetworkSettings{Proxy: "::invalid"}, ErrProxyURL},
+			{NetworkSettings{CABundle: filepath.Join(t.TempDir(), "missing.pem")}, ErrCABundle},
+		} {
+			ConfigureSyncClient(invalid.settings)
+
+			_, _, err := NewJSONBlobBackend("http://tx.invalid/", SyncAuth{}).Fetch("id")
+
+			var syncErr *SyncError
+			if !errors.As(err, &syncErr) || syncErr.Code != invalid.code {
+				t.Fatalf("Invalid settings should fail with %d, got %v", invalid.code, err)
+			}
+		}
+	})
+
+	t.Run("syncfile", func(t *testing.T) {
+		var s NetworkSettings
+
+		for _, line := range []string{"timeout: 5s", "connectTimeout: 2s", "retries: 0", "proxy: http://proxy:3128", "caBundle: /etc/ca.pem"} {
+			AssertEqual(t, s.ParseLine(line), true, "Setting was not parsed: "+line)
+		}
+
+		NetworkOptions.Timeout = 7 * time.Second
+		defer func() { NetworkOptions.Timeout = 0 }()
+
+		s = s.withOptions()
+
+		AssertEqual(t, s.Timeout, 7*time.Second, "Global option does not override the syncfile")
+		AssertEqual(t, s.ConnectTimeout, 2*time.Second, "Connect timeout does not match")
+		AssertEqual(t, *s.Retries, 0, "Disabled retries were replaced by the default")
+		AssertEqual(t, s.Proxy, "http://proxy:3128", "Proxy does not match")
+		AssertEqual(t, s.CABundle, "/etc/ca.pem", "CA bundle does not match")
+	})
+
+	syncClient, syncClientErr = nil, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// IDLength is the number of random bytes in a task ID. IDs have the same
+// length as the SHA-1 sums used as IDs by t, so tasklists stay compatible.
+const IDLength = 20
+
+// NewID generates a random task ID.
+func NewID() string {
+	id := make([]byte, IDLength)
+
+	if _, err := rand.Read(id); err != nil {
+		Error(ErrGenerateTaskID, err)
+	}
+
+	return hex.EncodeToString(id)
+}
+
+// IDSelectorPrefix starts selectors referring to tasks by their ID. Tags and
+// contexts start with "+" or "@", so the two never overlap.
+const IDSelectorPrefix = "id:"
+
+// IsIDSelector reports whether a selector refers to tasks by their ID, like
+// "id:3fa9" or "id:3fa9,id:c01d".
+func IsIDSelector(selector string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(selector)), IDSelectorPrefix)
+}
+
+// FindIDPrefix returns the indexes of the tasks whose ID starts with the
+// provided prefix. Like in git, the prefix has to identify a single ID; tasks
+// which share an ID (identical tasks created by older versions) are selected
+// together.
+func (tl *Tasklist) FindIDPrefix(prefix string) (indexes []int, err error) {
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+
+	if !IDPrefixPattern.MatchString(prefix) {
+		return nil, fmt.Errorf("invalid ID \"%s\"", prefix)
+	}
+
+	match := ""
+
+	for _, index := range tl.OrderKeys() {
+		id := tl.tasks[index].hash
+
+		if !strings.HasPrefix(id, prefix) {
+			continue
+		}
+
+		if match != "" && id != match {
+			return nil, fmt.Errorf("ambiguous ID \"%s\"", prefix)
+		}
+
+		match = id
+		indexes = append(indexes, index)
+	}
+
+	if match == "" {
+		return nil, fmt.Errorf("unknown ID \"%s\"", prefix)
+	}
+
+	return
+}
+
+// selectIDs converts an ID selector (see IsIDSelector) to indexes.
+func (tl *Tasklist) selectIDs(selector string) (indexes []int, err error) {
+	for _, part := range strings.Split(selector, ",") {
+		part = strings.TrimSpace(part)
+
+		if !IsIDSelector(part) {
+			return nil, fmt.Errorf("invalid ID \"%s\"", part)
+		}
+
+		matches, err := tl.FindIDPrefix(part[len(IDSelectorPrefix):])
+
+		if err != nil {
+			return nil, err
+		}
+
+		indexes = append(indexes, matches...)
+	}
+
+	return
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+)
+
+// Journal actions recorded while a synced tasklist is used offline. Wiping a
+// tasklist is recorded as the removal of every task in it, so tasks added on
+// other devices in the meantime are kept.
+const (
+	JournalAdd        = "add"
+	JournalEdit       = "edit"
+	JournalFinish     = "finish"
+	JournalRemove     = "remove"
+	JournalRestore    = "restore"
+	JournalDeleteDone = "deleteDone"
+)
+
+// JournalOperation is a single mutating action on a tasklist. Tasks are
+// referenced by their hash, so operations can be replayed on a different
+// version of the tasklist.
+type JournalOperation struct {
+	Action string `json:"action"`
+	ID     string `json:"id,omitempty"`   // The hash of the affected task.
+	Task   string `json:"task,omitempty"` // The taskline after the action.
+}
+
+// ReadJournal reads all operations from the journalfile.
+func ReadJournal() (operations []JournalOperation) {
+	journalfile := OpenJournalfile(true)
+
+	if journalfile == nil {
+		return
+	}
+
+	defer journalfile.Close()
+
+	scanner := bufio.NewScanner(journalfile)
+
+	for scanner.Scan() {
+		var operation JournalOperation
+
+		if err := json.Unmarshal(scanner.Bytes(), &operation); err != nil {
+			Warn("Skipping invalid operation in journal \"%s\": %v", JournalfilePath, err)
+			continue
+		}
+
+		operations = append(operations, operation)
+	}
+
+	if err := scanner.Err(); err != nil {
+		Error(ErrJournalfileRead, JournalfilePath, err)
+	}
+
+	return
+}
+
+// AppendJournal appends operations to the journalfile.
+func AppendJournal(operations []JournalOperation) {
+	journalfile, err := os.OpenFile(JournalfilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+
+	if err != nil {
+		Error(ErrJournalfileOpen, JournalfilePath, err)
+	}
+
+	defer journalfile.Close()
+
+	for _, operation := range operations {
+		line, _ := json.Marshal(operation)
+
+		if _, err := journalfile.Write(append(line, '\n')); err != nil {
+			Error(ErrJournalfileWrite, JournalfilePath, err)
+		}
+	}
+}
+
+// ClearJournal removes the journalfile once its operations have been
+// uploaded.
+func ClearJournal() {
+	err := os.Remove(JournalfilePath)
+
+	if err != nil && !os.IsNotExist(err) {
+		Warn("Could not delete journal \"%s\": %v", JournalfilePath, err)
+	}
+}
+
+// ReplayJournal applies operations to the active and finished tasklists.
+// Operations referring to tasks which no longer exist are skipped. Changes to
+// existing tasks are replayed on top of their synced version, using base (the
+// last synced state) to tell which aspects of them were changed offline.
+func ReplayJournal(base Snapshot, operations []JournalOperation) {
+	// The last local version of every task changed in the journal.
+	versions := make(map[string]*SnapshotEntry)
+
+	previous := func(id string) *SnapshotEntry {
+		if entry, ok := versions[id]; ok {
+			return entry
+		}
+
+		return base.Get(id)
+	}
+
+	for _, operation := range operations {
+		var task Task
+
+		if operation.Task != "" {
+			parsed, err := ParseTask(operation.Task)
+
+			if err != nil && err.Error() == "ignore" {
+				continue
+			}
+
+			task = parsed
+		}
+
+		prev := previous(operation.ID)
+		versions[operation.ID] = &SnapshotEntry{task: task, done: operation.Action == JournalFinish}
+
+		switch operation.Action {
+		case JournalAdd:
+			MainList.Add(task)
+		case JournalEdit:
+			if index, ok := MainList.FindHash(operation.ID); ok {
+				MainList.tasks[index] = replayChange(prev, SnapshotEntry{task: task}, MainList.tasks[index])
+			}
+		case JournalFinish:
+			if index, ok := MainList.FindHash(operation.ID); ok {
+				finished := replayChange(prev, SnapshotEntry{task: task, done: true}, MainList.tasks[index])
+
+				DoneList.Add(finished)
+				MainList.Remove([]int{index})
+			}
+		case JournalRemove:
+			if index, ok := MainList.FindHash(operation.ID); ok {
+				MainList.Remove([]int{index})
+			}
+		case JournalRestore:
+			if index, ok := DoneList.FindHash(operation.ID); ok {
+				MainList.Add(DoneList.tasks[index])
+				DoneList.Remove([]int{index})
+			}
+		case JournalDeleteDone:
+			if index, ok := DoneList.FindHash(operation.ID); ok {
+				DoneList.Remove([]int{index})
+			}
+		default:
+			Warn("Skipping unknown operation \"%s\" in journal \"%s\"", operation.Action, JournalfilePath)
+		}
+	}
+}
+
+// replayChange applies an offline change to the synced version of a task,
+// keeping the changes made to other aspects of it on other devices. base is
+// the version of the task the change was made to, if known.
+func replayChange(base *SnapshotEntry, change SnapshotEntry, synced Task) Task {
+	remote := SnapshotEntry{task: synced}
+
+	if base == nil {
+		base = &remote
+	}
+
+	strategy := ConflictStrategy(ConfigOptions.OnConflict)
+	result, conflict := replayEntry(base, &change, &remote, strategy)
+
+	if conflict {
+		Warn("Task \"%s\" was changed both offline and on the Sync service. Keeping the %s version.", change.task.text, strategy)
+	}
+
+	return result.task
+}
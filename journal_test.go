@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestJournal(t *testing.T) {
+	vs := &versionedStorage{unavailable: true}
+	vs.setContents(mergeTaskA+mergeTaskB, "")
+	ts := httptest.NewServer(vs)
+	defer ts.Close()
+
+	// The Sync service is unavailable, so operations are journaled.
+	InitTestingSyncEnv(t, ts.URL)
+
+	taskfile := CreateTaskfile(TaskfilePath)
+	taskfile.WriteString(mergeTaskA + mergeTaskB)
+	taskfile.Close()
+
+	MainList = &Tasklist{}
+	DoneList = &Tasklist{}
+	ListManager = &TasklistManager{}
+	ListManager.EnsureInitialized(MainList)
+
+	AssertEqual(t, ListManager.source, Local, "Tasklist was loaded from an unavailable Sync service")
+
+	add("offline")
+	finish("1")
+	ListManager.Save()
+
+	AssertEqual(t, len(ReadJournal()), 2, "Offline operations were not journaled")
+
+	// Simulate a change from another device, then go online.
+	vs.setContents(mergeTaskA+mergeTaskB+mergeTaskC, "")
+	vs.unavailable = false
+
+	MainList = &Tasklist{}
+	DoneList = &Tasklist{}
+	ListManager = &TasklistManager{}
+	ListManager.EnsureInitialized(MainList)
+
+	AssertEqual(t, ListManager.source, Merged, "Journal was not replayed")
+
+	ListManager.Save()
+
+	if _, err := os.Stat(JournalfilePath); !os.IsNotExist(err) {
+		t.Fatal("Journal was not cleared after uploading")
+	}
+
+	for _, text := range []string{`"contents":"B |`, `C |`, `offline |`, `"doneContents":"A |`} {
+		if !strings.Contains(vs.body, text) {
+			t.Fatalf("Uploaded tasklist does not contain %s:\n%s", text, vs.body)
+		}
+	}
+}
+
+func TestReplayJournal(t *testing.T) {
+	const id = "7b91fb49a85ea06bb0276e70984d602e62e95ea5"
+
+	base := ParseSnapshot("[ancestor]", "A | id:"+id+", creation:2024/01/01/10/00, finished:1970/01/01/00/00, started:2024/01/02/09/00\n", "")
+
+	t.Run("finish", func(t *testing.T) {
+		InitEmptyTestingEnv(&MainList)
+		InitEmptyTestingEnv(&DoneList)
+
+		// The priority was changed on another device while the task was
+		// finished offline, stopping its timer.
+		remote, _ := ParseTask("A | id:" + id + ", creation:2024/01/01/10/00, finished:1970/01/01/00/00, priority:B, started:2024/01/02/09/00")
+		MainList.Add(remote)
+
+		ReplayJournal(base, []JournalOperation{{
+			Action: JournalFinish,
+			ID:     id,
+			Task:   "A | id:" + id + ", creation:2024/01/01/10/00, finished:2024/01/02/10/00, tracked:2024/01/02=60",
+		}})
+
+		finished := DoneList.tasks[1]
+
+		AssertEqual(t, len(MainList.tasks), 0, "Task was not finished")
+		AssertEqual(t, string(finished.Serialize()), "A | id:"+id+", creation:2024/01/01/10/00, finished:2024/01/02/10/00, priority:B, tracked:2024/01/02=60\n", "Finished task does not combine both changes")
+	})
+
+	t.Run("edit", func(t *testing.T) {
+		InitEmptyTestingEnv(&MainList)
+		InitEmptyTestingEnv(&DoneList)
+
+		// The priority and the note were changed on another device, while the
+		// text, the due date and the note were edited offline.
+		remote, _ := ParseTask("A | id:" + id + ", creation:2024/01/01/10/00, finished:1970/01/01/00/00, priority:B, started:2024/01/02/09/00, note:remote")
+		MainList.Add(remote)
+
+		ReplayJournal(base, []JournalOperation{
+			{Action: JournalEdit, ID: id, Task: "A edited | id:" + id + ", creation:2024/01/01/10/00, finished:1970/01/01/00/00, started:2024/01/02/09/00"},
+			{Action: JournalEdit, ID: id, Task: "A edited | id:" + id + ", creation:2024/01/01/10/00, finished:1970/01/01/00/00, due:2024/02/01/23/59, started:2024/01/02/09/00, note:local"},
+		})
+
+		edited := MainList.tasks[1]
+
+		AssertEqual(t, string(edited.Serialize()), "A edited | id:"+id+", creation:2024/01/01/10/00, finished:1970/01/01/00/00, priority:B, due:2024/02/01/23/59, started:2024/01/02/09/00, note:local\n", "Edited task does not combine both changes")
+	})
+}
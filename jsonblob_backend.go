@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// JSONHeaders are the set of HTTP headers required to accept the body in JSON.
+var JSONHeaders = http.Header{
+	"Content-Type": {"application/json"},
+	"Accept":       {"application/json"},
+}
+
+// JSONBlobBackend is a SyncBackend for HTTP services mimicking the JSON Blob
+// API. The Sync ID is appended to the Sync URL to access a tasklist.
+type JSONBlobBackend struct {
+	url    string
+	auth   SyncAuth
+	header http.Header
+
+	// serverETags is set once the Sync service sent an entity tag, so that
+	// conditional uploads can rely on If-Match.
+	serverETags bool
+}
+
+// NewJSONBlobBackend creates a JSONBlobBackend for the provided Sync URL.
+func NewJSONBlobBackend(syncURL string, auth SyncAuth) SyncBackend {
+	EnsureTrailingSlash(&syncURL)
+
+	return &JSONBlobBackend{url: syncURL, auth: auth}
+}
+
+// headers returns the JSON headers along with the credentials configured for
+// the Sync service. Secrets are only read once.
+func (b *JSONBlobBackend) headers() (http.Header, error) {
+	if b.header == nil {
+		header, err := b.auth.HTTPHeaders()
+
+		if err != nil {
+			return nil, err
+		}
+
+		for name, values := range JSONHeaders {
+			header[name] = values
+		}
+
+		b.header = header
+	}
+
+	return b.header.Clone(), nil
+}
+
+// statusError converts an unexpected response status into a SyncError.
+func statusError(url string, resp *http.Response) error {
+	switch resp.StatusCode {
+	case 401:
+		return NewSyncError(ErrUnauthorized, url)
+	case 403:
+		return NewSyncError(ErrForbidden, url)
+	default:
+		return NewSyncError(ErrInvalidResponse, url, "status is "+resp.Status)
+	}
+}
+
+// Create requests a new Sync ID using a POST request. The Sync ID is
+// extracted from the Location header of the response.
+func (b *JSONBlobBackend) Create() (syncID string, err error) {
+	header, err := b.headers()
+
+	if err != nil {
+		return "", err
+	}
+
+	request, err := http.NewRequest("POST", b.url, nil)
+
+	if err != nil {
+		return "", NewSyncError(ErrRequestSyncID, b.url, err)
+	}
+
+	request.Header = header
+
+	client, err := SyncClient()
+
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.Do(request)
+
+	if err != nil {
+		return "", NewSyncError(ErrRequestSyncID, b.url, err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 201 {
+		return "", statusError(b.url, resp)
+	}
+
+	// Extract the new syncID from the updated location header.
+	location, err := resp.Location()
+
+	if err != nil {
+		return "", NewSyncError(ErrInvalidResponse, b.url, err)
+	}
+
+	locationString := location.String()
+
+	return locationString[strings.LastIndex(locationString, "/")+1:], nil
+}
+
+// Fetch downloads a tasklist using a GET request. If the Sync service does not
+// provide an entity tag, the SHA-1 sum of the response is used instead.
+func (b *JSONBlobBackend) Fetch(syncID string) (data []byte, etag string, err error) {
+	url := b.url + syncID
+
+	header, err := b.headers()
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	request, err := http.NewRequest("GET", url, nil)
+
+	if err != nil {
+		Error(ErrGETReqCreate, url, err)
+	}
+
+	request.Header = header
+
+	client, err := SyncClient()
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	resp, err := client.Do(request)
+
+	if err != nil {
+		return nil, "", NewSyncError(ErrGETReqComplete, url, err)
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case 404:
+		return nil, "", NewSyncError(ErrNoSyncID, syncID)
+	case 200:
+		data, err = io.ReadAll(resp.Body)
+
+		if err != nil {
+			return nil, "", NewSyncError(ErrInvalidResponse, url, err)
+		}
+
+		etag = resp.Header.Get("ETag")
+		b.serverETags = etag != ""
+
+		if etag == "" {
+			etag = `"` + hexHash(string(data)) + `"`
+		}
+
+		return data, etag, nil
+	default:
+		return nil, "", statusError(url, resp)
+	}
+}
+
+// Store uploads a tasklist using a PUT request. Conditional uploads send the
+// entity tag in the If-Match header. Services which do not provide entity
+// tags most likely ignore If-Match, so the tasklist is fetched again right
+// before the upload and its SHA-1 sum compared instead. Changes uploaded by
+// another device between that check and the PUT request still go undetected.
+func (b *JSONBlobBackend) Store(syncID string, data []byte, etag string) (newETag string, err error) {
+	url := b.url + syncID
+
+	if etag != "" && !b.serverETags {
+		_, current, err := b.Fetch(syncID)
+
+		if err != nil {
+			return "", err
+		}
+
+		if !b.serverETags && current != etag {
+			return "", errPreconditionFailed
+		}
+	}
+
+	header, err := b.headers()
+
+	if err != nil {
+		return "", err
+	}
+
+	request, err := http.NewRequest("PUT", url, bytes.NewReader(data))
+
+	if err != nil {
+		Error(ErrPUTReqCreate, url, err)
+	}
+
+	request.Header = header
+
+	if etag != "" {
+		request.Header.Set("If-Match", etag)
+	}
+
+	client, err := SyncClient()
+
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.Do(request)
+
+	if err != nil {
+		return "", NewSyncError(ErrPUTReqComplete, url, err)
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case 404:
+		return "", NewSyncError(ErrNoSyncID, syncID)
+	case 412:
+		return "", errPreconditionFailed
+	case 200:
+		newETag = resp.Header.Get("ETag")
+
+		if newETag == "" {
+			newETag = `"` + hexHash(string(data)) + `"`
+		}
+
+		return newETag, nil
+	default:
+		return "", statusError(url, resp)
+	}
+}
+
+// Delete removes a tasklist using a DELETE request.
+func (b *JSONBlobBackend) Delete(syncID string) error {
+	url := b.url + syncID
+
+	header, err := b.headers()
+
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest("DELETE", url, nil)
+
+	if err != nil {
+		Error(ErrDELETEReqCreate, url, err)
+	}
+
+	request.Header = header
+
+	client, err := SyncClient()
+
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(request)
+
+	if err != nil {
+		return NewSyncError(ErrDELETEReqComplete, url, err)
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case 404:
+		return NewSyncError(ErrNoSyncID, syncID)
+	case 405:
+		return NewSyncError(ErrUnsupportedConfig, url)
+	case 200:
+		return nil
+	default:
+		return statusError(url, resp)
+	}
+}
+
+// init gets called when the package is imported; registers the backend for
+// HTTP Sync URLs.
+func init() {
+	RegisterSyncBackend("http", NewJSONBlobBackend)
+	RegisterSyncBackend("https", NewJSONBlobBackend)
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"io"
 	"os"
 	"time"
 )
@@ -13,6 +12,7 @@ type Tasklist struct {
 	modified   bool         // States whether the tasklist has been modified.
 	serialized []byte       // Stores the serialzed tasks before saving.
 	loaded     bool         // True if the task has finished loading.
+	backedUp   bool         // True if a backup has been taken in this run.
 }
 
 // LoadLocal reads the provided taskfile and parses tasks into the tasklist.
@@ -30,21 +30,9 @@ func (tl *Tasklist) LoadLocal() {
 
 // SaveLocal serializes and writes tasks to the provided tasklist.
 func (tl *Tasklist) SaveLocal() {
-	if !ConfigOptions.Reckless {
-		src := OpenTaskfile(tl.filePath, true)
-
-		if src != nil {
-			defer src.Close()
-
-			backupFilePath := GetMetafilePath(".bak", tl.filePath)
-			dst := CreateTaskfile(backupFilePath)
-
-			_, err := io.Copy(dst, src)
-
-			if err != nil {
-				Error(ErrBackupWrite, backupFilePath, err)
-			}
-		}
+	// Only back up the taskfile as it was before tx modified it.
+	if !tl.backedUp {
+		tl.backedUp = BackupTaskfile(tl.filePath)
 	}
 
 	if tl.IsEmpty() {
@@ -57,7 +45,7 @@ func (tl *Tasklist) SaveLocal() {
 		} else {
 			err := os.Truncate(tl.filePath, 0)
 
-			if err != nil {
+			if err != nil && !os.IsNotExist(err) {
 				Error(ErrTaskfileWrite, tl.filePath, err)
 			}
 		}
@@ -8,6 +8,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // MarkModified sets the underlying modfied flag to true.
@@ -31,6 +32,46 @@ func (tl *Tasklist) OrderKeys() (keys []int) {
 	return
 }
 
+// Orders accepted by SortKeys.
+const (
+	SortIndex    = "index"
+	SortPriority = "priority"
+	SortDue      = "due"
+)
+
+// TaskFilter reports whether a task should be listed.
+type TaskFilter func(task Task) bool
+
+// SortKeys orders the keys of the tasklist for displaying. Tasks which are
+// equal in the requested order keep their index order.
+func (tl *Tasklist) SortKeys(order string) (keys []int) {
+	keys = tl.OrderKeys()
+
+	switch order {
+	case SortPriority:
+		sort.SliceStable(keys, func(i, j int) bool {
+			return tl.tasks[keys[i]].priorityRank() < tl.tasks[keys[j]].priorityRank()
+		})
+	case SortDue:
+		sort.SliceStable(keys, func(i, j int) bool {
+			return tl.tasks[keys[i]].dueRank() < tl.tasks[keys[j]].dueRank()
+		})
+	}
+
+	return
+}
+
+// FindHash returns the index of the first task with the provided hash.
+func (tl *Tasklist) FindHash(hash string) (index int, ok bool) {
+	for _, index := range tl.OrderKeys() {
+		if tl.tasks[index].hash == hash {
+			return index, true
+		}
+	}
+
+	return 0, false
+}
+
 // Add adds a task to the tasklist.
 func (tl *Tasklist) Add(newTask Task) {
 	err := newTask.Validate()
@@ -68,38 +109,154 @@ func (tl *Tasklist) Remove(indexes []int) {
 }
 
 // Show generates and prints the output according to a user-provided format
-// string.
-func (tl *Tasklist) Show(format string) {
+// string. Only tasks accepted by every filter are printed. Subtasks are listed
+// after their parent and indented, unless the format places them itself using
+// {depth}. Snoozed tasks are handled according to the view (see HideSnoozed);
+// if they are hidden, their number is printed after the tasks.
+func (tl *Tasklist) Show(format string, snoozeView string, filters ...TaskFilter) {
 	if tl.IsEmpty() {
 		return
 	}
 
 	keys := tl.OrderKeys()
 
-	padding := fmt.Sprintf("%%%dd", 1+len(keys)/10)
+	// Indexes are assigned in taskfile order, regardless of the order the
+	// tasks are listed in.
+	displayIndexes := make(map[int]int, len(keys))
 
 	for displayIndex, index := range keys {
+		displayIndexes[index] = displayIndex + 1
+	}
+
+	padding := fmt.Sprintf("%%%dd", 1+len(keys)/10)
+	now := Now()
+
+	attrKeys := AttributePlaceholderPattern.FindAllStringSubmatch(format, -1)
+	indent := !strings.Contains(format, "{depth}")
+	showProgress := strings.Contains(format, "{progress}")
+	treeKeys, depths := tl.TreeKeys(OutputOptions.Sort)
+	snoozed := 0
+
+tasks:
+	for _, index := range treeKeys {
 		task := tl.tasks[index]
 
+		for _, filter := range filters {
+			if !filter(task) {
+				continue tasks
+			}
+		}
+
+		listed, hidden := snoozeFilter(task, snoozeView, now)
+
+		if hidden {
+			snoozed++
+		}
+
+		if !listed {
+			continue
+		}
+
 		creationDate := task.creationDate.Format(DateFormat)
 		creationTime := task.creationDate.Format(DisplayTimeFormat)
 
 		finishedDate := task.finishedDate.Format(DateFormat)
 		finishedTime := task.finishedDate.Format(DisplayTimeFormat)
 
-		replacer := strings.NewReplacer(
-			"{index}", fmt.Sprintf(padding, displayIndex+1),
+		// Counting subtasks requires going through both tasklists.
+		var progress string
+
+		if showProgress {
+			progress = Progress(task)
+		}
+
+		// Attributes are looked up by the keys used in the format.
+		var attrs []string
+
+		for _, m := range attrKeys {
+			value, _ := task.Attr(m[1])
+			attrs = append(attrs, m[0], value)
+		}
+
+		replacer := strings.NewReplacer(append([]string{
+			"{index}", fmt.Sprintf(padding, displayIndexes[index]),
 			"{creationDate}", creationDate,
 			"{creationTime}", creationTime,
 			"{finishedDate}", finishedDate,
 			"{finishedTime}", finishedTime,
 			"{task}", task.text,
+			"{id}", task.hash,
+			"{priority}", task.priority,
+			"{tags}", strings.Join(task.tags, " "),
+			"{dueDate}", formatOptionalDate(task.due, DateFormat),
+			"{dueTime}", formatOptionalDate(task.due, DisplayTimeFormat),
+			"{dueIn}", DueIn(task.due, now),
+			"{waitDate}", waitDate(task, now),
+			"{depth}", strconv.Itoa(depths[index]),
+			"{progress}", progress,
+			"{recurrence}", task.recurrence,
+			"{note}", FormatNote(task.note),
+			"{hasNote}", hasNote(task),
+			"{blockedBy}", blockedBy(task),
+			"{elapsed}", FormatElapsed(task.TrackedTime(now).Total()),
+			"{running}", formatRunning(task, now),
 			"{{", "{",
 			"}}", "}",
-		)
+		}, attrs...)...)
+
+		line := replacer.Replace(format)
+
+		if indent {
+			line = strings.Repeat(SubtaskIndent, depths[index]) + line
+		}
 
-		fmt.Println(replacer.Replace(format))
+		fmt.Println(line)
 	}
+
+	printSnoozed(snoozed)
+}
+
+// blockedBy returns the comma-separated indexes of the active tasks blocking a
+// task.
+func blockedBy(task Task) string {
+	keys := MainList.OrderKeys()
+
+	var indexes []string
+
+	for _, b := range MainList.Blockers(task) {
+		indexes = append(indexes, strconv.Itoa(displayIndex(keys, b)))
+	}
+
+	return strings.Join(indexes, ",")
+}
+
+// waitDate returns the date a snoozed task wakes up, or an empty string if
+// it is not snoozed.
+func waitDate(task Task, now time.Time) string {
+	if !task.IsSnoozed(now) {
+		return ""
+	}
+
+	return task.wait.Format(DateFormat)
+}
+
+// hasNote returns a marker for tasks with a note.
+func hasNote(task Task) string {
+	if task.note == "" {
+		return ""
+	}
+
+	return "*"
+}
+
+// formatOptionalDate formats a date, or returns an empty string if it is
+// unset.
+func formatOptionalDate(date time.Time, layout string) string {
+	if date.IsZero() {
+		return ""
+	}
+
+	return date.Format(layout)
 }
 
 // InterpretSelectorPart converts a selector token to a concrete index.
@@ -118,11 +275,42 @@ func (tl *Tasklist) InterpretSelectorPart(part string, keys []int) (result int)
 	return
 }
 
+// ExitOnSelectorError prints why a selector could not be converted to indexes
+// and exits. The reason an ID selector failed (e.g.: an ambiguous prefix) is
+// more helpful than a generic invalid selector error.
+func ExitOnSelectorError(err error, caller string, selector string) {
+	if err == nil {
+		return
+	}
+
+	if IsIDSelector(selector) {
+		Error(ErrInvalidTaskID, err)
+	}
+
+	Error(ErrInvalidSelector, caller, selector)
+}
+
 // SelectTasks converts a "selector" string to a slice of valid indexes.
 func (tl *Tasklist) SelectTasks(selector string) (indexes []int, err error) {
 	selector = strings.TrimSpace(selector)
 	keys := tl.OrderKeys()
 
+	// ID notation
+	if IsIDSelector(selector) {
+		return tl.selectIDs(selector)
+	}
+
+	// Tag notation
+	if IsTagSelector(selector) {
+		for _, key := range keys {
+			if tl.tasks[key].HasTag(selector) {
+				indexes = append(indexes, key)
+			}
+		}
+
+		return
+	}
+
 	// Range notation
 	indexRange := regexp.MustCompile(`([rfl]|\d+)-([rfl]|\d+)`).FindStringSubmatch(selector)
 
@@ -206,9 +394,11 @@ func (tl *Tasklist) ParseTasklines(source string, reader io.Reader) {
 	}
 }
 
-// SerializeTasks serializes all tasks and appends them to the `serialized`
+// SerializeTasks serializes all tasks and stores them in the `serialized`
 // field of the tasklist.
 func (tl *Tasklist) SerializeTasks() {
+	tl.serialized = nil
+
 	for _, index := range tl.OrderKeys() {
 		task := tl.tasks[index]
 		tl.serialized = append(tl.serialized, task.Serialize()...)
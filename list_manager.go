@@ -1,20 +1,12 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
 	"encoding/json"
-	"net/http"
+	"errors"
 	"strings"
 	"time"
 )
 
-// JSONHeaders are the set of HTTP headers required to accept the body in JSON.
-var JSONHeaders = http.Header{
-	"Content-Type": {"application/json"},
-	"Accept":       {"application/json"},
-}
-
 // LoadSource is a type used for the Local and Network constants.
 type LoadSource int
 
@@ -29,6 +21,10 @@ const (
 	// taskfile is newer than the last network update, and will upload the
 	// local tasklist to the appropriate sync service.
 	OutdatedNetwork
+	// Merged notes that both the local taskfiles and the synced tasklist have
+	// changed since the last network update and were merged. The result will
+	// be saved locally and uploaded to the appropriate sync service.
+	Merged
 )
 
 // TasklistManager is responsible for loading and saving taskfiles, both local
@@ -39,57 +35,62 @@ type TasklistManager struct {
 	syncID            string
 	syncURL           string
 	lastNetworkUpdate time.Time
-}
-
-// ParseSyncfile reads the appropriate syncfile and sets the manager's fields
-// to their values.
-func (tm *TasklistManager) ParseSyncfile() {
-	syncfile := OpenSyncfile(true)
-
-	if syncfile == nil {
-		return
-	}
+	encryption        string
+	keySource         string
+	encryptedUpload   bool // Plaintext is rejected once set, see decrypt.
+	auth              SyncAuth
+	network           NetworkSettings
 
-	defer syncfile.Close()
+	backend SyncBackend
 
-	scanner := bufio.NewScanner(syncfile)
+	deviceID   string                     // Identifies the uploader in payloads.
+	etag       string                     // The entity tag of the last fetched/uploaded tasklist.
+	remote     Snapshot                   // The last fetched tasklist.
+	extensions map[string]json.RawMessage // Kept from the last fetched payload.
 
-	for scanner.Scan() {
-		line := scanner.Text()
+	journal []JournalOperation // Operations to record while offline.
 
-		// Extract Sync ID
-		syncIDMatch := SyncIDPattern.FindStringSubmatch(line)
+	workspace *Workspace // The workspace the tasklist is synced with, if any.
+}
 
-		if len(syncIDMatch) == 2 {
-			tm.syncID = syncIDMatch[1]
-			continue
-		}
+// MaxUploadAttempts is the number of times tx tries to upload a tasklist when
+// the synced tasklist keeps changing in the meantime.
+const MaxUploadAttempts = 3
 
-		// Extract Sync URL
-		syncURLMatch := SyncURLPattern.FindStringSubmatch(line)
+// ParseSyncfile reads the appropriate syncfile and sets the manager's fields
+// to their values.
+func (tm *TasklistManager) ParseSyncfile() {
+	tm.parseSyncfileAt(SyncfilePath)
+}
 
-		if len(syncURLMatch) == 2 {
-			tm.syncURL = syncURLMatch[1]
-			continue
-		}
+// parseSyncfileAt reads the syncfile at the provided path and sets the
+// manager's fields to their values.
+func (tm *TasklistManager) parseSyncfileAt(syncfilePath string) {
+	tm.applySyncfile(ReadSyncfile(syncfilePath))
+}
 
-		// Extract lastNetworkUpdate
-		lastNetworkUpdateMatch := LastNetworkUpdatePattern.FindStringSubmatch(line)
+// applySyncfile sets the manager's fields to the values of a syncfile.
+func (tm *TasklistManager) applySyncfile(sf *Syncfile) {
+	tm.syncID = sf.SyncID
+	tm.syncURL = sf.SyncURL
+	tm.deviceID = sf.DeviceID
 
-		if len(lastNetworkUpdateMatch) == 2 {
-			lastNetworkUpdate, err := time.Parse(LastNetworkUpdateFormat, lastNetworkUpdateMatch[1])
+	if sf.LastNetworkUpdate != nil {
+		tm.lastNetworkUpdate = *sf.LastNetworkUpdate
+	}
 
-			if err != nil {
-				Warn("Invalid lastNetworkUpdate date in syncfile \"%s\": %s", SyncfilePath, lastNetworkUpdateMatch[1])
-			}
+	if sf.Encryption != nil {
+		tm.encryption = sf.Encryption.Scheme
+		tm.keySource = sf.Encryption.KeySource
+		tm.encryptedUpload = sf.Encryption.Uploaded
+	}
 
-			tm.lastNetworkUpdate = lastNetworkUpdate
-			continue
-		}
+	if sf.Auth != nil {
+		tm.auth = *sf.Auth
 	}
 
-	if err := scanner.Err(); err != nil {
-		Error(ErrSyncfileRead, SyncfilePath, err)
+	if sf.Network != nil {
+		tm.network = *sf.Network
 	}
 }
 
@@ -109,96 +110,177 @@ func (tm *TasklistManager) Init() {
 func (tm *TasklistManager) Load() {
 	tm.source = func() LoadSource {
 		// "Bouncer" statements
+		tm.ParseSyncfile()
+
+		if tm.syncID == "" {
+			tm.workspace = FindWorkspace(TaskfilePath)
+		}
+
 		if ConfigOptions.Offline {
 			return Local
 		}
 
-		tm.ParseSyncfile()
+		// Lists of a workspace are synced as a whole and then loaded from
+		// the local taskfiles.
+		if tm.workspace != nil {
+			tm.syncWorkspace()
+			return Local
+		}
 
 		if tm.syncID == "" {
 			return Local
 		}
 
-		if tm.syncURL == "" {
-			tm.syncURL = ConfigOptions.FallbackSyncURL
+		localNewer := MainList.MTimeAfter(tm.lastNetworkUpdate) || DoneList.MTimeAfter(tm.lastNetworkUpdate)
+
+		tm.InitBackend()
+
+		contents, doneContents, err := tm.fetch()
+
+		if IsAuthError(err) {
+			ExitOnSyncError(err, tm.syncURL)
 		}
 
-		if MainList.MTimeAfter(tm.lastNetworkUpdate) || DoneList.MTimeAfter(tm.lastNetworkUpdate) {
-			Warn("Local tasklist is newer than the synced one. Uploading local tasklist.")
-			return OutdatedNetwork
+		if err != nil {
+			Warn("%v", err)
+			return Local
 		}
 
-		// Prepare URL
-		tm.syncURL = strings.TrimSpace(tm.syncURL)
-		EnsureTrailingSlash(&tm.syncURL)
+		// Operations made while offline are replayed on top of the synced
+		// tasklist, which is more precise than merging the local taskfiles.
+		if journal := ReadJournal(); len(journal) > 0 {
+			Warn("Replaying %d operation(s) made while offline.", len(journal))
 
-		tm.syncID = strings.TrimSpace(tm.syncID)
+			MainList.ParseTasklines("[syncID:"+tm.syncID+"]", strings.NewReader(contents))
+			DoneList.ParseTasklines("[syncID:"+tm.syncID+"]", strings.NewReader(doneContents))
 
-		url := tm.syncURL + tm.syncID
+			MainList.loaded = true
+			DoneList.loaded = true
 
-		// Complete GET Request
-		request, err := http.NewRequest("GET", url, nil)
+			ReplayJournal(LoadBaseSnapshot(), journal)
 
-		if err != nil {
-			Error(ErrGETReqCreate, url, err)
+			MainList.MarkModified()
+			DoneList.MarkModified()
+
+			return Merged
 		}
 
-		request.Header = JSONHeaders
+		if localNewer {
+			Warn("Local tasklist is newer than the last synced one. Merging local and synced changes.")
 
-		resp, err := http.DefaultClient.Do(request)
+			MainList.LoadLocal()
+			DoneList.LoadLocal()
 
-		if err != nil {
-			Warn("Could not complete GET request: %v", err)
-			return Local
+			tm.merge(LoadBaseSnapshot(), contents, doneContents)
+
+			return Merged
 		}
 
-		defer resp.Body.Close()
+		// Parse active and finished tasklists
+		{
+			reader := strings.NewReader(contents)
 
-		statusCode := resp.StatusCode
+			MainList.ParseTasklines("[syncID:"+tm.syncID+"]", reader)
+		}
 
-		switch statusCode {
-		case 404:
-			Warn("Could not find tasklist with Sync ID \"%s\"", tm.syncID)
-			return Local
-		case 200:
-			// Unmarshal JSON stirng into map[string]string
-			var jsonData []byte
+		{
+			reader := strings.NewReader(doneContents)
 
-			for s := bufio.NewScanner(resp.Body); s.Scan(); {
-				jsonData = append(jsonData, s.Bytes()...)
-			}
+			DoneList.ParseTasklines("[syncID:"+tm.syncID+"]", reader)
 
-			var data map[string]interface{}
+		}
 
-			if json.Unmarshal(jsonData, &data) != nil {
-				Warn("Failed to parse network response: %v", err)
-				return Local
-			}
+		MainList.loaded = true
+		DoneList.loaded = true
 
-			// Parse active and finished tasklists
-			{
-				reader := strings.NewReader(data["contents"].(string))
+		return Network
+	}()
+}
 
-				MainList.ParseTasklines("[syncID:"+tm.syncID+"]", reader)
-			}
+// InitBackend prepares the Sync ID and Sync URL parsed from the syncfile and
+// creates the appropriate sync backend.
+func (tm *TasklistManager) InitBackend() {
+	if tm.syncURL == "" {
+		tm.syncURL = ConfigOptions.FallbackSyncURL
+	}
 
-			{
-				reader := strings.NewReader(data["doneContents"].(string))
+	// Prepare URL
+	tm.syncURL = strings.TrimSpace(tm.syncURL)
+	EnsureTrailingSlash(&tm.syncURL)
 
-				DoneList.ParseTasklines("[syncID:"+tm.syncID+"]", reader)
+	tm.syncID = strings.TrimSpace(tm.syncID)
 
-			}
+	ConfigureSyncClient(tm.network)
 
-			MainList.loaded = true
-			DoneList.loaded = true
+	tm.backend = NewSyncBackend(tm.syncURL, tm.auth)
+}
 
-			return Network
-		default:
-			Warn("Invalid response when loading tasklist: status is " + resp.Status)
-			return Local
+// fetch downloads the synced tasklist and records its entity tag.
+func (tm *TasklistManager) fetch() (contents string, doneContents string, err error) {
+	jsonData, etag, err := tm.backend.Fetch(tm.syncID)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	payload, err := ParsePayload(tm.decrypt(jsonData))
+
+	if err != nil {
+		return "", "", NewSyncError(ErrInvalidPayload, tm.syncID, err)
+	}
+
+	tm.etag = etag
+	tm.extensions = payload.Extensions
+
+	contents = payload.Contents
+	doneContents = payload.DoneContents
+
+	tm.remote = ParseSnapshot("[syncID:"+tm.syncID+"]", contents, doneContents)
+
+	return
+}
+
+// merge merges the loaded tasklists with the synced tasklist, using base as
+// their common ancestor.
+func (tm *TasklistManager) merge(base Snapshot, contents string, doneContents string) {
+	local := NewSnapshot(MainList, DoneList)
+	remote := ParseSnapshot("[syncID:"+tm.syncID+"]", contents, doneContents)
+
+	strategy := ConflictStrategy(ConfigOptions.OnConflict)
+	merged, conflicts := MergeSnapshots(base, local, remote, strategy)
+
+	for _, key := range conflicts {
+		var text string
+
+		if entry := local.Get(key); entry != nil {
+			text = entry.task.text
+		} else {
+			text = remote.Get(key).task.text
 		}
 
-	}()
+		Warn("Task \"%s\" was changed both locally and on the Sync service. Keeping the %s version.", text, strategy)
+	}
+
+	merged.Apply(MainList, DoneList)
+
+	MainList.MarkModified()
+	DoneList.MarkModified()
+}
+
+// Record notes a mutating operation. Operations are only journaled if the
+// tasklist is synced but could not be loaded from the network.
+func (tm *TasklistManager) Record(action string, task Task) {
+	if tm.source != Local || tm.syncID == "" || task.hash == "" {
+		return
+	}
+
+	operation := JournalOperation{Action: action, ID: task.hash}
+
+	if action == JournalAdd || action == JournalEdit || action == JournalFinish {
+		operation.Task = string(task.Serialize())
+	}
+
+	tm.journal = append(tm.journal, operation)
 }
 
 // EnsureInitialized makes sure that the tasklist is loaded and does so if not.
@@ -228,10 +310,61 @@ func (tm *TasklistManager) Save() {
 		return
 	}
 
+	tm.saveLocal()
+
+	if len(tm.journal) > 0 {
+		AppendJournal(tm.journal)
+	}
+
+	if tm.workspace != nil && !ConfigOptions.Offline {
+		tm.syncWorkspace()
+	}
+
+	// Upload to Sync service. If the synced tasklist has changed since it was
+	// loaded, merge the changes and try again.
+	if tm.source > Local {
+		for attempt := 1; !tm.upload(); attempt++ {
+			url := tm.syncURL + tm.syncID
+
+			if attempt == MaxUploadAttempts {
+				Error(ErrSyncConflict, url, attempt)
+			}
+
+			Warn("The synced tasklist has changed since it was loaded. Merging and retrying.")
+
+			base := tm.remote
+			contents, doneContents, err := tm.fetch()
+
+			ExitOnSyncError(err, url)
+
+			tm.merge(base, contents, doneContents)
+			tm.saveLocal()
+		}
+	}
+
+	RunCallback()
+}
+
+// syncWorkspace syncs the workspace of the tasklist. Failures other than
+// rejected credentials only cause a warning, as the local taskfiles will be
+// merged on the next successful sync.
+func (tm *TasklistManager) syncWorkspace() {
+	err := tm.workspace.Sync()
+
+	if IsAuthError(err) {
+		ExitOnSyncError(err, tm.workspace.tm.syncURL)
+	}
+
+	if err != nil {
+		Warn("Could not sync workspace \"%s\": %v", tm.workspace.dir, err)
+	}
+}
+
+// saveLocal serializes the tasklists and saves the modified ones locally.
+func (tm *TasklistManager) saveLocal() {
 	MainList.SerializeTasks()
 	DoneList.SerializeTasks()
 
-	// Save local taskfiles
 	if MainList.modified {
 		MainList.SaveLocal()
 	}
@@ -239,54 +372,125 @@ func (tm *TasklistManager) Save() {
 	if DoneList.modified {
 		DoneList.SaveLocal()
 	}
+}
 
-	// Upload to Sync service
-	if tm.source > Local {
-		// Marshal into JSON
-		jsonMap := map[string]string{
-			"contents":     string(MainList.serialized),
-			"doneContents": string(DoneList.serialized),
-		}
+// upload sends the serialized tasklists to the Sync service. Unless the
+// upload is forced, the request is conditional on the synced tasklist being
+// unchanged since it was fetched. Returns false if that condition failed.
+func (tm *TasklistManager) upload() bool {
+	jsonData := tm.payload()
 
-		jsonData, err := json.Marshal(jsonMap)
+	var etag string
 
-		if err != nil {
-			Error(ErrSerializeJSON, err)
-		}
+	if tm.source != OutdatedNetwork {
+		etag = tm.etag
+	}
 
-		// Complete PUT Request
-		url := tm.syncURL + tm.syncID
-		request, err := http.NewRequest("PUT", url, bytes.NewReader(jsonData))
+	newETag, err := tm.backend.Store(tm.syncID, tm.encrypt(jsonData), etag)
 
-		if err != nil {
-			Error(ErrPUTReqCreate, url, err)
-		}
+	if errors.Is(err, errPreconditionFailed) {
+		return false
+	}
 
-		request.Header = JSONHeaders
+	ExitOnSyncError(err, tm.syncURL)
 
-		resp, err := http.DefaultClient.Do(request)
+	tm.etag = newETag
+	tm.markSynced(jsonData)
 
-		if err != nil {
-			Error(ErrPUTReqComplete, url, err)
-		}
+	return true
+}
+
+// payload marshals the serialized tasklists into the JSON object stored on
+// the Sync service.
+func (tm *TasklistManager) payload() []byte {
+	updatedAt := Now().UTC()
+
+	jsonData, err := json.Marshal(Payload{
+		Version:      PayloadVersion,
+		Device:       tm.deviceID,
+		UpdatedAt:    &updatedAt,
+		Contents:     string(MainList.serialized),
+		DoneContents: string(DoneList.serialized),
+		Extensions:   tm.extensions,
+	})
+
+	if err != nil {
+		Error(ErrSerializeJSON, err)
+	}
+
+	return jsonData
+}
+
+// markSynced records that the local taskfiles and the synced tasklist are
+// identical.
+func (tm *TasklistManager) markSynced(jsonData []byte) {
+	var list SyncedList
+
+	if err := json.Unmarshal(jsonData, &list); err != nil {
+		Error(ErrSerializeJSON, err)
+	}
+
+	UpdateSyncfile(SyncfilePath, func(sf *Syncfile) {
+		newLastNetworkUpdate := Now()
+
+		sf.LastNetworkUpdate = &newLastNetworkUpdate
+		sf.ETag = tm.etag
+		sf.markEncryptedUpload()
 
-		defer resp.Body.Close()
+		// Both sides are identical now, which makes the synced data the
+		// common ancestor of future merges.
+		sf.Ancestors = map[string]SyncedList{ListName(TaskfilePath): list}
+	})
 
-		statusCode := resp.StatusCode
+	ClearJournal()
+}
+
+// encrypt encrypts the serialized tasklists if encryption is enabled for the
+// tasklist.
+func (tm *TasklistManager) encrypt(jsonData []byte) []byte {
+	if tm.encryption == "" {
+		return jsonData
+	}
+
+	if tm.encryption != EncryptionAES256GCM {
+		Error(ErrEncryptPayload, "unsupported encryption \""+tm.encryption+"\"")
+	}
+
+	encrypted, err := EncryptPayload(jsonData, ReadEncryptionSecret(tm.keySource))
+
+	if err != nil {
+		Error(ErrEncryptPayload, err)
+	}
+
+	return encrypted
+}
 
-		switch statusCode {
-		case 404:
-			Error(ErrNoSyncID, tm.syncID)
-		case 200:
-			// Update LastNetworkUpdate value in Syncfile
-			newLastNetworkUpdate := StripNanoFromTime(time.Now())
-			line := "lastNetworkUpdate: " + newLastNetworkUpdate.Format(LastNetworkUpdateFormat) + "\n"
+// decrypt decrypts downloaded data if it is encrypted. Plaintext data is only
+// accepted until the first encrypted upload after enabling encryption, so the
+// Sync service cannot downgrade the tasklist to plaintext later on. Encrypted
+// data is never passed on undecrypted.
+func (tm *TasklistManager) decrypt(data []byte) []byte {
+	if !IsEncryptedPayload(data) {
+		if tm.encryption != "" && tm.encryptedUpload {
+			Error(ErrDecryptPayload, tm.syncID, "tasklist is not encrypted, although an encrypted one was uploaded")
+		}
 
-			ReplaceOrAppendSyncfileLine(LastNetworkUpdatePattern, line)
-		default:
-			Error(ErrInvalidResponse, url, "status is "+resp.Status)
+		if tm.encryption != "" {
+			Warn("Synced tasklist is not encrypted. It will be encrypted on the next upload.")
 		}
+
+		return data
 	}
 
-	RunCallback()
+	if tm.encryption == "" {
+		Error(ErrDecryptPayload, tm.syncID, "tasklist is encrypted, but encryption is not enabled. Use `tx sync enable --encrypt`.")
+	}
+
+	plaintext, err := DecryptPayload(data, ReadEncryptionSecret(tm.keySource))
+
+	if err != nil {
+		Error(ErrDecryptPayload, tm.syncID, err)
+	}
+
+	return plaintext
 }
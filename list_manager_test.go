@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// versionedStorage is a dummy Sync service which supports conditional
+// uploads using entity tags.
+type versionedStorage struct {
+	body        string
+	version     int
+	alwaysFail  bool
+	unavailable bool
+}
+
+// setContents replaces the stored tasklist with an unencrypted one.
+func (vs *versionedStorage) setContents(contents string, doneContents string) {
+	data, _ := json.Marshal(map[string]string{
+		"contents":     contents,
+		"doneContents": doneContents,
+	})
+
+	vs.body = string(data)
+}
+
+func (vs *versionedStorage) etag() string {
+	return fmt.Sprintf(`"v%d"`, vs.version)
+}
+
+func (vs *versionedStorage) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if vs.unavailable {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	switch req.Method {
+	case "GET":
+		w.Header().Set("ETag", vs.etag())
+		w.Write([]byte(vs.body))
+	case "PUT":
+		ifMatch := req.Header.Get("If-Match")
+
+		if vs.alwaysFail || (ifMatch != "" && ifMatch != vs.etag()) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+
+		body, _ := io.ReadAll(req.Body)
+
+		vs.body = string(body)
+		vs.version++
+
+		w.Header().Set("ETag", vs.etag())
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// InitTestingSyncEnv points a fresh ListManager to the provided Sync service
+// and loads the tasklists from it.
+func InitTestingSyncEnv(t *testing.T, url string) {
+	InitTestingPathVariables(t)
+
+	(&Syncfile{SyncID: "testing-sync-id", SyncURL: url}).Write(SyncfilePath)
+
+	MainList = &Tasklist{}
+	DoneList = &Tasklist{}
+	ListManager = &TasklistManager{}
+
+	ListManager.EnsureInitialized(MainList)
+}
+
+func TestConditionalUpload(t *testing.T) {
+	t.Run("retry", func(t *testing.T) {
+		vs := &versionedStorage{}
+		vs.setContents(mergeTaskA, "")
+		ts := httptest.NewServer(vs)
+		defer ts.Close()
+
+		InitTestingSyncEnv(t, ts.URL)
+
+		AssertEqual(t, ListManager.source, Network, "Tasklist was not loaded from the Sync service")
+
+		// Simulate a change from another device.
+		vs.setContents(mergeTaskA+mergeTaskB, "")
+		vs.version++
+
+		add("C")
+		ListManager.Save()
+
+		AssertEqual(t, vs.version, 2, "Merged tasklist was not uploaded")
+
+		if !strings.Contains(vs.body, "B |") || !strings.Contains(vs.body, "C |") {
+			t.Fatalf("Uploaded tasklist is missing changes:\n%s", vs.body)
+		}
+	})
+
+	t.Run("give_up", func(t *testing.T) {
+		AssertExitError(t, "TestConditionalUpload/give_up", ErrSyncConflict, func() {
+			vs := &versionedStorage{alwaysFail: true}
+			vs.setContents(mergeTaskA, "")
+			ts := httptest.NewServer(vs)
+			defer ts.Close()
+
+			InitTestingSyncEnv(t, ts.URL)
+
+			add("C")
+			ListManager.Save()
+		})
+	})
+}
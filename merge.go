@@ -0,0 +1,382 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConflictStrategy decides which side wins when the same task was changed
+// differently both locally and on the Sync service.
+type ConflictStrategy string
+
+const (
+	// KeepLocal keeps the local version of a conflicting task.
+	KeepLocal ConflictStrategy = "local"
+	// KeepRemote keeps the synced version of a conflicting task.
+	KeepRemote ConflictStrategy = "remote"
+)
+
+// SnapshotEntry is a task captured in a Snapshot along with the tasklist it
+// belongs to.
+type SnapshotEntry struct {
+	task Task
+	done bool
+}
+
+// Snapshot is a view of an active and a finished tasklist keyed by task hash.
+// Snapshots are the units of the three-way merge used when syncing.
+type Snapshot struct {
+	keys    []string
+	entries map[string]SnapshotEntry
+}
+
+// NewSnapshot captures the tasks of an active and a finished tasklist.
+func NewSnapshot(main *Tasklist, done *Tasklist) (s Snapshot) {
+	s.entries = make(map[string]SnapshotEntry)
+
+	s.capture(main, false)
+	s.capture(done, true)
+
+	return
+}
+
+// ParseSnapshot parses serialized active and finished tasklists into a
+// Snapshot.
+func ParseSnapshot(source string, contents string, doneContents string) Snapshot {
+	main := &Tasklist{tasks: make(map[int]Task)}
+	done := &Tasklist{tasks: make(map[int]Task)}
+
+	main.ParseTasklines(source, strings.NewReader(contents))
+	done.ParseTasklines(source, strings.NewReader(doneContents))
+
+	return NewSnapshot(main, done)
+}
+
+// capture adds the tasks of a tasklist to the snapshot. Identical tasks share
+// a hash, so repeated hashes are suffixed with their occurrence count.
+func (s *Snapshot) capture(tl *Tasklist, done bool) {
+	for _, index := range tl.OrderKeys() {
+		task := tl.tasks[index]
+
+		hash := task.hash
+
+		if hash == "" {
+			hash = hexHash(task.text)
+		}
+
+		key := hash
+
+		for n := 2; ; n++ {
+			if _, exists := s.entries[key]; !exists {
+				break
+			}
+
+			key = fmt.Sprintf("%s#%d", hash, n)
+		}
+
+		s.keys = append(s.keys, key)
+		s.entries[key] = SnapshotEntry{task: task, done: done}
+	}
+}
+
+// Get returns the entry stored under a key, or nil if there is none.
+func (s Snapshot) Get(key string) *SnapshotEntry {
+	entry, ok := s.entries[key]
+
+	if !ok {
+		return nil
+	}
+
+	return &entry
+}
+
+// Apply replaces the tasks of an active and a finished tasklist with the
+// contents of the snapshot.
+func (s Snapshot) Apply(main *Tasklist, done *Tasklist) {
+	main.tasks = make(map[int]Task)
+	done.tasks = make(map[int]Task)
+
+	for _, key := range s.keys {
+		entry := s.entries[key]
+
+		if entry.done {
+			done.Add(entry.task)
+		} else {
+			main.Add(entry.task)
+		}
+	}
+}
+
+// Serialize returns the tasklines of the active and the finished tasks in the
+// snapshot.
+func (s Snapshot) Serialize() (contents string, doneContents string) {
+	main := &Tasklist{tasks: make(map[int]Task)}
+	done := &Tasklist{tasks: make(map[int]Task)}
+
+	s.Apply(main, done)
+
+	main.SerializeTasks()
+	done.SerializeTasks()
+
+	return string(main.serialized), string(done.serialized)
+}
+
+// MergeSnapshots performs a three-way merge of the local and the remote
+// snapshot using base as their common ancestor. Changes made on only one side
+// are applied as-is. Tasks changed differently on both sides are resolved
+// using the provided strategy and their keys are returned as conflicts.
+func MergeSnapshots(base, local, remote Snapshot, strategy ConflictStrategy) (merged Snapshot, conflicts []string) {
+	merged.entries = make(map[string]SnapshotEntry)
+
+	// Keep the local order and append tasks only known to the remote.
+	keys := append([]string{}, local.keys...)
+
+	for _, key := range remote.keys {
+		if local.Get(key) == nil {
+			keys = append(keys, key)
+		}
+	}
+
+	// Tasks removed on one side may still be present in the base only.
+	for _, key := range base.keys {
+		if local.Get(key) == nil && remote.Get(key) == nil {
+			keys = append(keys, key)
+		}
+	}
+
+	for _, key := range keys {
+		b, l, r := base.Get(key), local.Get(key), remote.Get(key)
+
+		result, conflict := mergeEntry(b, l, r)
+
+		if conflict {
+			conflicts = append(conflicts, key)
+
+			if strategy == KeepRemote {
+				result = r
+			} else {
+				result = l
+			}
+		}
+
+		if result != nil {
+			merged.keys = append(merged.keys, key)
+			merged.entries[key] = *result
+		}
+	}
+
+	return
+}
+
+// entryAspect is a part of a task which can be changed independently on both
+// sides of a merge.
+type entryAspect struct {
+	get  func(e *SnapshotEntry) string
+	copy func(dst *SnapshotEntry, src *SnapshotEntry)
+}
+
+// entryAspects lists every aspect of a task considered when merging.
+var entryAspects = []entryAspect{
+	{
+		get: func(e *SnapshotEntry) string { return e.task.text },
+		copy: func(dst, src *SnapshotEntry) {
+			dst.task.text = src.task.text
+			dst.task.tags = src.task.tags
+		},
+	},
+	{
+		get: func(e *SnapshotEntry) string { return fmt.Sprint(e.done) },
+		copy: func(dst, src *SnapshotEntry) {
+			dst.done = src.done
+			dst.task.finishedDate = src.task.finishedDate
+		},
+	},
+	{
+		get:  func(e *SnapshotEntry) string { return e.task.priority },
+		copy: func(dst, src *SnapshotEntry) { dst.task.priority = src.task.priority },
+	},
+	{
+		get:  func(e *SnapshotEntry) string { return e.task.due.Format(FullDateFormat) },
+		copy: func(dst, src *SnapshotEntry) { dst.task.due = src.task.due },
+	},
+	{
+		get:  func(e *SnapshotEntry) string { return e.task.wait.Format(FullDateFormat) },
+		copy: func(dst, src *SnapshotEntry) { dst.task.wait = src.task.wait },
+	},
+	{
+		get:  func(e *SnapshotEntry) string { return e.task.parent },
+		copy: func(dst, src *SnapshotEntry) { dst.task.parent = src.task.parent },
+	},
+	{
+		get:  func(e *SnapshotEntry) string { return e.task.recurrence },
+		copy: func(dst, src *SnapshotEntry) { dst.task.recurrence = src.task.recurrence },
+	},
+	{
+		get:  func(e *SnapshotEntry) string { return e.task.note },
+		copy: func(dst, src *SnapshotEntry) { dst.task.note = src.task.note },
+	},
+	{
+		get:  func(e *SnapshotEntry) string { return strings.Join(e.task.blockedBy, BlockerSeparator) },
+		copy: func(dst, src *SnapshotEntry) { dst.task.blockedBy = src.task.blockedBy },
+	},
+	{
+		get:  func(e *SnapshotEntry) string { return e.task.tracked.String() },
+		copy: func(dst, src *SnapshotEntry) { dst.task.tracked = src.task.tracked },
+	},
+	{
+		get:  func(e *SnapshotEntry) string { return e.task.started.Format(FullDateFormat) },
+		copy: func(dst, src *SnapshotEntry) { dst.task.started = src.task.started },
+	},
+	{
+		get:  func(e *SnapshotEntry) string { return serializeAttributes(e.task.attributes) },
+		copy: func(dst, src *SnapshotEntry) { dst.task.attributes = src.task.attributes },
+	},
+}
+
+// mergeEntry merges a single task. Changes to different aspects of a task
+// (e.g. finished on one side, edited on the other) are combined; only
+// incompatible changes are reported as a conflict.
+func mergeEntry(b, l, r *SnapshotEntry) (result *SnapshotEntry, conflict bool) {
+	if sameEntry(l, r) {
+		return l, false
+	}
+
+	if sameEntry(l, b) {
+		return r, false
+	}
+
+	if sameEntry(r, b) {
+		return l, false
+	}
+
+	if b == nil || l == nil || r == nil {
+		return nil, true
+	}
+
+	merged := *l
+
+	for _, aspect := range entryAspects {
+		base, local, remote := aspect.get(b), aspect.get(l), aspect.get(r)
+
+		if remote == base {
+			continue
+		}
+
+		if local != base && local != remote {
+			return nil, true
+		}
+
+		aspect.copy(&merged, r)
+	}
+
+	return &merged, false
+}
+
+// replayEntry applies the aspects of a task changed from b to l onto r, keeping
+// the changes made to other aspects in r. Aspects changed differently in l and
+// r are resolved using the provided strategy and reported as a conflict.
+func replayEntry(b, l, r *SnapshotEntry, strategy ConflictStrategy) (result SnapshotEntry, conflict bool) {
+	result = *r
+
+	for _, aspect := range entryAspects {
+		base, local, remote := aspect.get(b), aspect.get(l), aspect.get(r)
+
+		if local == base || local == remote {
+			continue
+		}
+
+		if remote != base {
+			conflict = true
+
+			if strategy == KeepRemote {
+				continue
+			}
+		}
+
+		aspect.copy(&result, l)
+	}
+
+	return
+}
+
+// sameEntry reports whether two entries hold the same task in the same
+// tasklist. Two missing entries are considered equal.
+func sameEntry(a, b *SnapshotEntry) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.done == b.done && string(a.task.Serialize()) == string(b.task.Serialize())
+}
+
+// LoadBaseSnapshot reads the last synced state of the tasklist from the
+// syncfile. A tasklist which has never been synced results in an empty
+// snapshot.
+func LoadBaseSnapshot() Snapshot {
+	base := ReadSyncfile(SyncfilePath).Ancestors[ListName(TaskfilePath)]
+
+	return ParseSnapshot("[ancestor]", base.Contents, base.DoneContents)
+}
+
+// Change kinds reported by DiffSnapshots.
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeEdited   = "edited"
+	ChangeFinished = "finished"
+	ChangeRestored = "restored"
+)
+
+// SnapshotChange describes how a task differs from its version in another
+// snapshot.
+type SnapshotChange struct {
+	Kind  string
+	Entry SnapshotEntry
+}
+
+// DiffSnapshots lists the changes needed to turn base into other, matching
+// tasks by their hash.
+func DiffSnapshots(base Snapshot, other Snapshot) (changes []SnapshotChange) {
+	for _, key := range other.keys {
+		b, o := base.Get(key), other.Get(key)
+
+		switch {
+		case b == nil:
+			changes = append(changes, SnapshotChange{ChangeAdded, *o})
+		case !b.done && o.done:
+			changes = append(changes, SnapshotChange{ChangeFinished, *o})
+		case b.done && !o.done:
+			changes = append(changes, SnapshotChange{ChangeRestored, *o})
+		case !sameEntry(b, o):
+			changes = append(changes, SnapshotChange{ChangeEdited, *o})
+		}
+	}
+
+	for _, key := range base.keys {
+		if other.Get(key) == nil {
+			changes = append(changes, SnapshotChange{ChangeRemoved, *base.Get(key)})
+		}
+	}
+
+	return
+}
+
+// SyncStatus compares the local and the remote snapshot with their common
+// ancestor and returns the matching Status[...] exit code.
+func SyncStatus(base, local, remote Snapshot) int {
+	switch {
+	case SameSnapshot(local, remote):
+		return StatusInSync
+	case len(DiffSnapshots(base, remote)) == 0:
+		return StatusLocalAhead
+	case len(DiffSnapshots(base, local)) == 0:
+		return StatusRemoteAhead
+	default:
+		return StatusDiverged
+	}
+}
+
+// SameSnapshot reports whether two snapshots hold the same tasks.
+func SameSnapshot(a Snapshot, b Snapshot) bool {
+	return len(a.keys) == len(b.keys) && len(DiffSnapshots(a, b)) == 0
+}
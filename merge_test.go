@@ -0,0 +1,124 @@
+package main
+
+import (
+	"testing"
+)
+
+const (
+	mergeTaskA = "A | id:6dcd4ce23d88e2ee9568ba546c007c63d9131c1b, creation:2021/01/28/09/59, finished:1970/01/01/00/00\n"
+	mergeTaskB = "B | id:ae4f281df5a5d0ff3cad6371f76d5c29b6d953ec, creation:2021/01/28/10/00, finished:1970/01/01/00/00\n"
+	mergeTaskC = "C | id:32096c2e0eff33d844ee6d675407ace18289357d, creation:2021/01/28/10/01, finished:1970/01/01/00/00\n"
+)
+
+// AssertSnapshotTexts fails the current test if the active and finished tasks
+// of a snapshot do not match the provided texts in order.
+func AssertSnapshotTexts(t *testing.T, s Snapshot, active []string, finished []string) {
+	var gotActive, gotFinished []string
+
+	for _, key := range s.keys {
+		entry := s.entries[key]
+
+		if entry.done {
+			gotFinished = append(gotFinished, entry.task.text)
+		} else {
+			gotActive = append(gotActive, entry.task.text)
+		}
+	}
+
+	AssertEqual(t, len(gotActive), len(active), "Number of active tasks does not match")
+	AssertEqual(t, len(gotFinished), len(finished), "Number of finished tasks does not match")
+
+	for i := range active {
+		AssertEqual(t, gotActive[i], active[i], "Active task text does not match")
+	}
+
+	for i := range finished {
+		AssertEqual(t, gotFinished[i], finished[i], "Finished task text does not match")
+	}
+}
+
+func TestMergeSnapshots(t *testing.T) {
+	base := ParseSnapshot("base", mergeTaskA+mergeTaskB, "")
+
+	t.Run("both_add", func(t *testing.T) {
+		local := ParseSnapshot("local", mergeTaskA+mergeTaskB+"local | id:1111111111111111111111111111111111111111, creation:2021/01/28/11/00, finished:1970/01/01/00/00\n", "")
+		remote := ParseSnapshot("remote", mergeTaskA+mergeTaskB+mergeTaskC, "")
+
+		merged, conflicts := MergeSnapshots(base, local, remote, KeepLocal)
+
+		AssertEqual(t, len(conflicts), 0, "Unexpected conflicts")
+		AssertSnapshotTexts(t, merged, []string{"A", "B", "local", "C"}, nil)
+	})
+
+	t.Run("finish_and_remove", func(t *testing.T) {
+		local := ParseSnapshot("local", mergeTaskB, mergeTaskA)
+		remote := ParseSnapshot("remote", mergeTaskA, "")
+
+		merged, conflicts := MergeSnapshots(base, local, remote, KeepLocal)
+
+		AssertEqual(t, len(conflicts), 0, "Unexpected conflicts")
+		AssertSnapshotTexts(t, merged, nil, []string{"A"})
+	})
+
+	t.Run("edit_and_finish", func(t *testing.T) {
+		local := ParseSnapshot("local", "A edited | id:6dcd4ce23d88e2ee9568ba546c007c63d9131c1b, creation:2021/01/28/09/59, finished:1970/01/01/00/00\n"+mergeTaskB, "")
+		remote := ParseSnapshot("remote", mergeTaskB, mergeTaskA)
+
+		merged, conflicts := MergeSnapshots(base, local, remote, KeepLocal)
+
+		AssertEqual(t, len(conflicts), 0, "Unexpected conflicts")
+		AssertSnapshotTexts(t, merged, []string{"B"}, []string{"A edited"})
+	})
+
+	t.Run("edit_and_priority", func(t *testing.T) {
+		local := ParseSnapshot("local", "A edited | id:6dcd4ce23d88e2ee9568ba546c007c63d9131c1b, creation:2021/01/28/09/59, finished:1970/01/01/00/00\n"+mergeTaskB, "")
+		remote := ParseSnapshot("remote", "A | id:6dcd4ce23d88e2ee9568ba546c007c63d9131c1b, creation:2021/01/28/09/59, finished:1970/01/01/00/00, priority:A\n"+mergeTaskB, "")
+
+		merged, conflicts := MergeSnapshots(base, local, remote, KeepLocal)
+
+		AssertEqual(t, len(conflicts), 0, "Unexpected conflicts")
+		AssertSnapshotTexts(t, merged, []string{"A edited", "B"}, nil)
+		AssertEqual(t, merged.entries[merged.keys[0]].task.priority, "A", "Remote priority was not merged")
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		local := ParseSnapshot("local", "A local | id:6dcd4ce23d88e2ee9568ba546c007c63d9131c1b, creation:2021/01/28/09/59, finished:1970/01/01/00/00\n"+mergeTaskB, "")
+		remote := ParseSnapshot("remote", "A remote | id:6dcd4ce23d88e2ee9568ba546c007c63d9131c1b, creation:2021/01/28/09/59, finished:1970/01/01/00/00\n"+mergeTaskB, "")
+
+		merged, conflicts := MergeSnapshots(base, local, remote, KeepLocal)
+
+		AssertEqual(t, len(conflicts), 1, "Conflicting edit was not reported")
+		AssertSnapshotTexts(t, merged, []string{"A local", "B"}, nil)
+
+		merged, _ = MergeSnapshots(base, local, remote, KeepRemote)
+
+		AssertSnapshotTexts(t, merged, []string{"A remote", "B"}, nil)
+	})
+
+	t.Run("duplicates", func(t *testing.T) {
+		local := ParseSnapshot("local", mergeTaskA+mergeTaskB+mergeTaskA, "")
+		remote := ParseSnapshot("remote", mergeTaskA+mergeTaskB, "")
+
+		merged, conflicts := MergeSnapshots(base, local, remote, KeepLocal)
+
+		AssertEqual(t, len(conflicts), 0, "Unexpected conflicts")
+		AssertSnapshotTexts(t, merged, []string{"A", "B", "A"}, nil)
+	})
+}
+
+func TestSyncStatus(t *testing.T) {
+	base := ParseSnapshot("base", mergeTaskA, "")
+	ahead := ParseSnapshot("ahead", mergeTaskA+mergeTaskB, "")
+	finished := ParseSnapshot("finished", "", mergeTaskA)
+
+	AssertEqual(t, SyncStatus(base, base, base), StatusInSync, "Identical tasklists are not in sync")
+	AssertEqual(t, SyncStatus(base, ahead, ahead), StatusInSync, "Identical changes are not in sync")
+	AssertEqual(t, SyncStatus(base, ahead, base), StatusLocalAhead, "Local changes are not detected")
+	AssertEqual(t, SyncStatus(base, base, finished), StatusRemoteAhead, "Remote changes are not detected")
+	AssertEqual(t, SyncStatus(base, ahead, finished), StatusDiverged, "Diverged tasklists are not detected")
+
+	changes := DiffSnapshots(base, finished)
+
+	AssertEqual(t, len(changes), 1, "Finishing a task is not a single change")
+	AssertEqual(t, changes[0].Kind, ChangeFinished, "Finished task is not reported as finished")
+}
@@ -99,7 +99,161 @@ const (
 	ErrUnsupportedConfig
 )
 
-var errorMessages = [25]string{
+// ErrBasefile[...] were used for the basefile, which held the last synced state
+// before the JSON syncfile. Only ErrBasefileRead is still used, when migrating
+// legacy syncfiles; the other codes are kept so later codes stay the same.
+// Messages require the filepath (type string) and the error (type error).
+const (
+	ErrBasefileOpen = 25 + iota
+	ErrBasefileWrite
+	ErrBasefileRead
+)
+
+const (
+	// ErrSyncConflict is used when the synced tasklist keeps changing while tx
+	// is trying to upload its changes. Message requires the URL (type string)
+	// and the number of attempts (type int).
+	ErrSyncConflict = 28 + iota
+	// ErrUnsupportedBackend is used when no sync backend is available for the
+	// scheme of a Sync URL. Message requires the URL (type string).
+	ErrUnsupportedBackend
+	// ErrGETReqComplete is used when a HTTP GET request cannot be completed.
+	// Message requires the URL (type string) and the error (type error).
+	ErrGETReqComplete
+	// ErrGitCommand is used when a git command run by the git sync backend
+	// fails. Message requires the repository (type string) and the error
+	// (type error).
+	ErrGitCommand
+)
+
+const (
+	// ErrEncryptionKey is used when the encryption key cannot be read from its
+	// configured source. Message requires the key source (type string) and
+	// the error (type error or string).
+	ErrEncryptionKey = 32 + iota
+	// ErrDecryptPayload is used when a synced tasklist cannot be decrypted,
+	// most likely because of a wrong key. Message requires the Sync ID
+	// (type string) and the error (type error or string).
+	ErrDecryptPayload
+	// ErrEncryptPayload is used when a tasklist cannot be encrypted before
+	// uploading. Message requires the error (type error).
+	ErrEncryptPayload
+)
+
+// ErrJournalfile[...] are used when handling file operations on a
+// journalfile. Messages require the filepath (type string) and the error
+// (type error).
+const (
+	ErrJournalfileOpen = 35 + iota
+	ErrJournalfileWrite
+	ErrJournalfileRead
+)
+
+const (
+	// ErrSyncDisabled is used when a sync operation is requested for a
+	// tasklist without a Sync ID. Message requires the syncfile path
+	// (type string).
+	ErrSyncDisabled = 38 + iota
+)
+
+const (
+	// ErrServerListen is used when the built-in Sync service cannot listen on
+	// the configured address. Message requires the address (type string) and
+	// the error (type error).
+	ErrServerListen = 39 + iota
+	// ErrServerStorage is used when the storage directory of the built-in Sync
+	// service cannot be created. Message requires the directory (type string)
+	// and the error (type error).
+	ErrServerStorage
+)
+
+const (
+	// ErrAuthSecret is used when the secret used for authenticating with the
+	// Sync service cannot be read. Message requires the secret source (type
+	// string) and the error (type error).
+	ErrAuthSecret = 41 + iota
+	// ErrUnauthorized is used when the Sync service responds with 401
+	// Unauthorized. Message requires the URL (type string).
+	ErrUnauthorized
+	// ErrForbidden is used when the Sync service responds with 403 Forbidden.
+	// Message requires the URL (type string).
+	ErrForbidden
+)
+
+const (
+	// ErrProxyURL is used when the configured proxy URL is invalid. Message
+	// requires the URL (type string).
+	ErrProxyURL = 44 + iota
+	// ErrCABundle is used when the configured CA bundle cannot be loaded.
+	// Message requires the path (type string) and the error (type error).
+	ErrCABundle
+)
+
+const (
+	// ErrWorkspaceRead is used when the directory of a workspace cannot be
+	// read. Message requires the directory (type string) and the error (type
+	// error).
+	ErrWorkspaceRead = 46 + iota
+	// ErrWorkspaceWrite is used when a list of a workspace cannot be written
+	// or deleted. Message requires the path (type string) and the error (type
+	// error).
+	ErrWorkspaceWrite
+)
+
+const (
+	// ErrSyncfileParse is used when a syncfile is not valid JSON. Message
+	// requires the path (type string) and the error (type error).
+	ErrSyncfileParse = 48 + iota
+	// ErrSyncfileVersion is used when a syncfile was written by a newer
+	// version of tx. Message requires the path (type string), the version of
+	// the syncfile (type int) and the supported version (type int).
+	ErrSyncfileVersion
+	// ErrInvalidPayload is used when the data stored for a tasklist or a
+	// workspace on the Sync service is malformed. Message requires the Sync ID
+	// (type string) and the error (type error).
+	ErrInvalidPayload
+)
+
+const (
+	// ErrInvalidPriority is used when a priority level is not a letter.
+	// Message requires the caller (type string) and the level (type string).
+	ErrInvalidPriority = 51 + iota
+	// ErrInvalidDate is used when a date given on the command line cannot be
+	// parsed. Message requires the caller (type string) and the date (type
+	// string).
+	ErrInvalidDate
+	// ErrInvalidRecurrence is used when a recurrence rule cannot be parsed.
+	// Message requires the caller (type string) and the rule (type string).
+	ErrInvalidRecurrence
+	// ErrNoteEditor is used when the editor for a note cannot be run. Message
+	// requires the caller (type string) and the error (type error).
+	ErrNoteEditor
+	// ErrInvalidAttribute is used when an attribute given on the command line
+	// has an invalid or reserved key. Message requires the caller (type
+	// string) and the attribute (type string).
+	ErrInvalidAttribute
+	// ErrInvalidTaskID is used when an ID selector does not match exactly one
+	// task ID. Message requires the error (type error).
+	ErrInvalidTaskID
+	// ErrGenerateTaskID is used when no random bytes are available for a new
+	// task ID. Message requires the error (type error).
+	ErrGenerateTaskID
+)
+
+// Status[...] are the exit codes of `tx sync status`. They are kept apart from
+// the error codes so scripts can tell them apart.
+const (
+	StatusInSync      = 0
+	StatusLocalAhead  = 100
+	StatusRemoteAhead = 101
+	StatusDiverged    = 102
+)
+
+// StatusOverdue is the exit code of `tx tasks --overdue` if any task is
+// overdue.
+const StatusOverdue = 103
+
+var errorMessages = [58]string{
 	"Argument parser: %v",
 	"%s: Invalid selector: \"%s\". Use --help for selector format information.",
 	"Edit: Invalid selector: \"%s\". Use SELECT/NEW or SELECT/OLD/NEW.",
@@ -132,6 +286,50 @@ var errorMessages = [25]string{
 	"Could not request new Sync ID from \"%s\": %v",
 	"Invalid response from \"%s\": %v",
 	"Unsupported configuration: Deleting this tasklist is disabled on \"%s\"",
+
+	"Could not open basefile \"%s\": %v",
+	"Could not write basefile \"%s\": %v",
+	"Could not read basefile \"%s\": %v",
+
+	"Could not upload to \"%s\": The synced tasklist kept changing (gave up after %d attempts)",
+	"No sync backend available for \"%s\"",
+	"Could not complete GET request for \"%s\": %v",
+	"Git command failed for repository \"%s\": %v",
+
+	"Could not read encryption key from \"%s\": %v",
+	"Could not decrypt tasklist with Sync ID \"%s\": %v",
+	"Could not encrypt tasklist: %v",
+
+	"Could not open journalfile \"%s\": %v",
+	"Could not write journalfile \"%s\": %v",
+	"Could not read journalfile \"%s\": %v",
+
+	"Syncing is not enabled, no Sync ID in \"%s\"",
+
+	"Could not serve on \"%s\": %v",
+	"Could not create storage directory \"%s\": %v",
+
+	"Could not read authentication secret \"%s\": %v",
+	"Authentication with the Sync service at \"%s\" failed (401 Unauthorized), check the credentials in the syncfile",
+	"Access to \"%s\" was denied by the Sync service (403 Forbidden)",
+
+	"Invalid proxy URL \"%s\"",
+	"Could not load CA bundle \"%s\": %v",
+
+	"Could not read workspace \"%s\": %v",
+	"Could not update workspace list \"%s\": %v",
+
+	"Could not parse syncfile \"%s\": %v",
+	"Syncfile \"%s\" has version %d, but this version of tx only supports up to %d. Please update tx.",
+	"Invalid tasklist with Sync ID \"%s\": %v",
+
+	"%s: Invalid priority: \"%s\". Use a letter from A (highest) to Z, or \"-\" to remove it.",
+	"%s: Invalid date: \"%s\". Use YYYY/MM/DD, YYYY/MM/DD/HH/MM, YYYY-MM-DD, today, tomorrow or +N followed by h, d, w or m.",
+	"%s: Invalid recurrence: \"%s\". Use N followed by d, w, m or y, daily, weekly, monthly, yearly or weekday, optionally prefixed with \"+\" for a fixed schedule.",
+	"%s: Could not edit note: %v. Set $EDITOR or use SELECT/TEXT.",
+	"%s: Invalid attribute: \"%s\". Use key=value with a key starting with a letter which is not used by tx itself.",
+	"Invalid ID selector: %v. Use id: followed by at least 4 characters of a task ID, matching a single ID.",
+	"Could not generate a task ID: %v",
 }
 
 // Error is used to print a standard error message then exit.
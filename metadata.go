@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Attribute is a key:value field of the metadata following the separator of a
+// taskline. Fields without a colon have an empty key and are stored in Value.
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// String converts the attribute to the form stored in the taskline metadata.
+func (a Attribute) String() string {
+	if a.Key == "" {
+		return a.Value
+	}
+
+	return a.Key + ":" + a.Value
+}
+
+// metadataKeys lists the metadata keys interpreted by tx. Every other field is
+// kept verbatim as an attribute of the task.
+var metadataKeys = map[string]bool{
+	"id":        true,
+	"creation":  true,
+	"finished":  true,
+	"priority":  true,
+	"due":       true,
+	"wait":      true,
+	"parent":    true,
+	"every":     true,
+	"blockedby": true,
+	"tracked":   true,
+	"started":   true,
+	"note":      true,
+}
+
+// attributeEscaper escapes the characters which would end a metadata field or
+// the taskline.
+var attributeEscaper = strings.NewReplacer("%", "%25", ",", "%2C", "|", "%7C", "\n", "%0A")
+
+// attributeUnescaper reverses attributeEscaper.
+var attributeUnescaper = strings.NewReplacer("%25", "%", "%2C", ",", "%2c", ",", "%7C", "|", "%7c", "|", "%0A", "\n", "%0a", "\n")
+
+// ParseMetadata splits the metadata of a taskline into fields. Fields are
+// separated by commas, keys and values by the first colon. Whitespace around
+// both is ignored.
+func ParseMetadata(metadata string) (fields []Attribute) {
+	for _, field := range strings.Split(metadata, ",") {
+		field = strings.TrimSpace(field)
+
+		if field == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(field, ":")
+
+		if !ok {
+			fields = append(fields, Attribute{Value: field})
+			continue
+		}
+
+		fields = append(fields, Attribute{Key: strings.TrimSpace(key), Value: strings.TrimSpace(value)})
+	}
+
+	return
+}
+
+// IsReservedKey reports whether a metadata key is interpreted by tx and
+// cannot be used for attributes.
+func IsReservedKey(key string) bool {
+	return metadataKeys[strings.ToLower(key)]
+}
+
+// ParseAttributeArg validates a key=value pair given on the command line.
+func ParseAttributeArg(arg string) (key string, value string, err error) {
+	key, value, _ = strings.Cut(arg, "=")
+	key = strings.TrimSpace(key)
+
+	if !AttributeKeyPattern.MatchString(key) || IsReservedKey(key) {
+		return "", "", fmt.Errorf("invalid attribute key \"%s\"", key)
+	}
+
+	return key, strings.TrimSpace(value), nil
+}
+
+// Attr returns the value of an attribute of the task. Keys are matched
+// case-insensitively.
+func (t Task) Attr(key string) (value string, ok bool) {
+	for _, attribute := range t.attributes {
+		if attribute.Key != "" && strings.EqualFold(attribute.Key, key) {
+			return attributeUnescaper.Replace(attribute.Value), true
+		}
+	}
+
+	return "", false
+}
+
+// SetAttr sets the value of an attribute, keeping its position if the task
+// already has it.
+func (t *Task) SetAttr(key string, value string) {
+	attribute := Attribute{Key: key, Value: attributeEscaper.Replace(value)}
+
+	// The attributes are copied, as tasks share them when copied themselves.
+	attributes := make([]Attribute, 0, len(t.attributes)+1)
+	replaced := false
+
+	for _, a := range t.attributes {
+		if a.Key != "" && strings.EqualFold(a.Key, key) {
+			if !replaced {
+				attributes = append(attributes, attribute)
+				replaced = true
+			}
+
+			continue
+		}
+
+		attributes = append(attributes, a)
+	}
+
+	if !replaced {
+		attributes = append(attributes, attribute)
+	}
+
+	t.attributes = attributes
+}
+
+// UnsetAttr removes an attribute from the task.
+func (t *Task) UnsetAttr(key string) {
+	var attributes []Attribute
+
+	for _, a := range t.attributes {
+		if a.Key == "" || !strings.EqualFold(a.Key, key) {
+			attributes = append(attributes, a)
+		}
+	}
+
+	t.attributes = attributes
+}
+
+// serializeAttributes returns the attributes of a task as stored in the
+// taskline metadata.
+func serializeAttributes(attributes []Attribute) string {
+	var fields []string
+
+	for _, a := range attributes {
+		fields = append(fields, a.String())
+	}
+
+	return strings.Join(fields, ", ")
+}
+
+// setAttribute sets an attribute of the selected tasks using a
+// SELECT/key=value argument.
+func setAttribute(cmd string) {
+	ListManager.EnsureInitialized(MainList)
+	exitOnEmptyTasks("Set")
+
+	selector, arg, ok := splitSelector(cmd)
+
+	if !ok {
+		Error(ErrInvalidSelector, "Set", cmd)
+	}
+
+	key, value, err := ParseAttributeArg(arg)
+
+	if err != nil {
+		Error(ErrInvalidAttribute, "Set", arg)
+	}
+
+	updateTasks("Set", selector, func(task *Task) { task.SetAttr(key, value) })
+}
+
+// unsetAttribute removes an attribute from the selected tasks using a
+// SELECT/key argument.
+func unsetAttribute(cmd string) {
+	ListManager.EnsureInitialized(MainList)
+	exitOnEmptyTasks("Unset")
+
+	selector, key, ok := splitSelector(cmd)
+
+	if !ok {
+		Error(ErrInvalidSelector, "Unset", cmd)
+	}
+
+	if _, _, err := ParseAttributeArg(key); err != nil {
+		Error(ErrInvalidAttribute, "Unset", key)
+	}
+
+	updateTasks("Unset", selector, func(task *Task) { task.UnsetAttr(strings.TrimSpace(key)) })
+}
+
+// updateTasks applies a change to the selected tasks and records it.
+func updateTasks(caller string, selector string, update func(task *Task)) {
+	indexes, err := MainList.SelectTasks(selector)
+
+	ExitOnSelectorError(err, caller, selector)
+
+	for _, i := range indexes {
+		task, ok := MainList.tasks[i]
+
+		if !ok {
+			Error(ErrInvalidIndex, caller, i)
+		}
+
+		update(&task)
+
+		MainList.tasks[i] = task
+		ListManager.Record(JournalEdit, task)
+	}
+
+	MainList.MarkModified()
+}
+
+// splitSelector splits a SELECT/VALUE argument at the first unescaped slash.
+// The value may contain slashes itself.
+func splitSelector(cmd string) (selector string, value string, ok bool) {
+	separator := SelectorSeparatorPattern.FindStringIndex(cmd)
+
+	if separator == nil {
+		return cmd, "", false
+	}
+
+	return strings.TrimSpace(cmd[:separator[0]+1]), cmd[separator[0]+2:], true
+}
+
+// attrFilter accepts tasks having an attribute given as KEY or KEY=VALUE.
+// Values are compared case-insensitively.
+func attrFilter(arg string) TaskFilter {
+	key, value, hasValue := strings.Cut(arg, "=")
+	key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+	return func(task Task) bool {
+		actual, ok := task.Attr(key)
+
+		return ok && (!hasValue || strings.EqualFold(actual, value))
+	}
+}
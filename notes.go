@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// DefaultEditor is used for editing notes if neither $VISUAL nor $EDITOR is
+// set.
+const DefaultEditor = "vi"
+
+// EscapeNote converts a note to the form stored in the taskline metadata.
+// Escaped notes contain no whitespace, colons, commas or pipes, so they cannot
+// be mistaken for other metadata.
+func EscapeNote(note string) string {
+	return url.QueryEscape(note)
+}
+
+// UnescapeNote reverses EscapeNote.
+func UnescapeNote(escaped string) (string, error) {
+	return url.QueryUnescape(escaped)
+}
+
+// FormatNote returns the note of a task on a single line, with line breaks
+// shown as "\n".
+func FormatNote(note string) string {
+	return strings.ReplaceAll(note, "\n", `\n`)
+}
+
+// EditNote opens the user's editor with a note and returns the edited note.
+func EditNote(note string) (string, error) {
+	editor := os.Getenv("VISUAL")
+
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+
+	if editor == "" {
+		editor = DefaultEditor
+	}
+
+	file, err := os.CreateTemp("", "tx-note-*.txt")
+
+	if err != nil {
+		return "", err
+	}
+
+	defer os.Remove(file.Name())
+
+	_, err = file.WriteString(note)
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	cmdLine := strings.Fields(editor)
+
+	command := exec.Command(cmdLine[0], append(cmdLine[1:], file.Name())...)
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+
+	if err := command.Run(); err != nil {
+		return "", err
+	}
+
+	contents, err := os.ReadFile(file.Name())
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(contents)), nil
+}
+
+// setNote sets the note of the selected tasks to the TEXT part of a
+// SELECT/TEXT argument, or edits the note of a single task in the user's
+// editor if only a selector is given.
+func setNote(cmd string) {
+	ListManager.EnsureInitialized(MainList)
+	exitOnEmptyTasks("Note")
+
+	// Notes may contain slashes themselves, e.g.: in links.
+	selector, text, hasText := splitSelector(cmd)
+	editing := !hasText
+
+	indexes, err := MainList.SelectTasks(selector)
+
+	ExitOnSelectorError(err, "Note", selector)
+
+	if editing && len(indexes) != 1 {
+		Error(ErrInvalidSelector, "Note", selector)
+	}
+
+	for _, i := range indexes {
+		task, ok := MainList.tasks[i]
+
+		if !ok {
+			Error(ErrInvalidIndex, "Note", i)
+		}
+
+		switch {
+		case editing:
+			if task.note, err = EditNote(task.note); err != nil {
+				Error(ErrNoteEditor, "Note", err)
+			}
+		case strings.TrimSpace(text) == "-":
+			task.note = ""
+		default:
+			task.note = strings.TrimSpace(strings.ReplaceAll(text, `\n`, "\n"))
+		}
+
+		MainList.tasks[i] = task
+		ListManager.Record(JournalEdit, task)
+	}
+
+	MainList.MarkModified()
+}
+
+// showTasks prints every detail of the selected tasks, including their notes.
+// The tasklist is not listed afterwards.
+func showTasks(selector string) {
+	ListManager.EnsureInitialized(MainList)
+	exitOnEmptyTasks("Show")
+	ListManager.EnsureInitialized(DoneList)
+
+	indexes, err := MainList.SelectTasks(selector)
+
+	ExitOnSelectorError(err, "Show", selector)
+
+	keys := MainList.OrderKeys()
+
+	for n, i := range indexes {
+		task, ok := MainList.tasks[i]
+
+		if !ok {
+			Error(ErrInvalidIndex, "Show", i)
+		}
+
+		if n > 0 {
+			fmt.Println()
+		}
+
+		printDetail("Index", fmt.Sprint(displayIndex(keys, i)))
+		printDetail("Task", task.text)
+		printDetail("Id", task.hash)
+		printDetail("Created", task.creationDate.Format(DateFormat+" "+DisplayTimeFormat))
+		printDetail("Priority", task.priority)
+
+		if !task.due.IsZero() {
+			printDetail("Due", fmt.Sprintf("%s (%s)", task.due.Format(DateFormat+" "+DisplayTimeFormat), DueIn(task.due, Now())))
+		}
+
+		if task.IsSnoozed(Now()) {
+			printDetail("Snoozed", "until "+task.wait.Format(DateFormat+" "+DisplayTimeFormat))
+		}
+
+		printDetail("Recurs", task.recurrence)
+		printDetail("Tags", strings.Join(task.tags, " "))
+
+		if parent, ok := MainList.FindHash(task.parent); ok && task.parent != "" {
+			printDetail("Parent", fmt.Sprintf("%d - %s", displayIndex(keys, parent), MainList.tasks[parent].text))
+		}
+
+		printDetail("Subtasks", Progress(task))
+
+		for _, attribute := range task.attributes {
+			if attribute.Key != "" {
+				value, _ := task.Attr(attribute.Key)
+				printDetail(attribute.Key, value)
+			}
+		}
+
+		if task.note != "" {
+			fmt.Println("Note:")
+
+			for _, line := range strings.Split(task.note, "\n") {
+				fmt.Println("  " + line)
+			}
+		}
+	}
+
+	taskActions.skipListing = true
+}
+
+// printDetail prints a labeled detail of a task, unless it is empty.
+func printDetail(label string, value string) {
+	if value != "" {
+		fmt.Printf("%-10s%s\n", label+":", value)
+	}
+}
+
+// displayIndex returns the index a task is listed with.
+func displayIndex(keys []int, index int) int {
+	for n, key := range keys {
+		if key == index {
+			return n + 1
+		}
+	}
+
+	return index
+}
@@ -2,31 +2,90 @@ package main
 
 import "regexp"
 
-// SyncIDPattern is used for extracting the Sync ID from a syncfile.
+// The syncfile patterns are only used for migrating legacy "key: value"
+// syncfiles, see ReadSyncfile.
+
+// SyncIDPattern is used for extracting the Sync ID from a legacy syncfile.
 var SyncIDPattern = regexp.MustCompile(`(?i)syncid[ \t]*:[ \t]*(.+)`)
 
-// SyncURLPattern is used for extracting the Sync service URL from a syncfile.
+// SyncURLPattern is used for extracting the Sync service URL from a legacy
+// syncfile.
 var SyncURLPattern = regexp.MustCompile(`(?i)syncurl[ \t]*:[ \t]*(.+)`)
 
 // LastNetworkUpdatePattern is used for extracting the last successful HTTP request
-// date from a syncfile.
+// date from a legacy syncfile.
 var LastNetworkUpdatePattern = regexp.MustCompile(`(?i)lastnetworkupdate[ \t]*:[ \t](\d{4}/\d{2}/\d{2}/\d{2}\/\d{2}\/\d{2})`)
 
+// EncryptionPattern is used for extracting the encryption scheme of synced
+// tasklists from a legacy syncfile.
+var EncryptionPattern = regexp.MustCompile(`(?i)encryption[ \t]*:[ \t]*(.+)`)
+
+// KeySourcePattern is used for extracting the source of the encryption key
+// from a legacy syncfile.
+var KeySourcePattern = regexp.MustCompile(`(?i)keysource[ \t]*:[ \t]*(.+)`)
+
+// AuthTypePattern is used for extracting the authentication scheme used with
+// the Sync service from a legacy syncfile.
+var AuthTypePattern = regexp.MustCompile(`(?i)authtype[ \t]*:[ \t]*(.+)`)
+
+// AuthUserPattern is used for extracting the username used for basic
+// authentication from a legacy syncfile.
+var AuthUserPattern = regexp.MustCompile(`(?i)authuser[ \t]*:[ \t]*(.+)`)
+
+// AuthSecretPattern is used for extracting the source of the token or
+// password used for authentication from a legacy syncfile.
+var AuthSecretPattern = regexp.MustCompile(`(?i)authsecret[ \t]*:[ \t]*(.+)`)
+
+// HeaderPattern is used for extracting extra HTTP headers sent to the Sync
+// service from a legacy syncfile. It may appear multiple times.
+var HeaderPattern = regexp.MustCompile(`(?i)^[ \t]*header[ \t]*:[ \t]*(.+)`)
+
+// TimeoutPattern is used for extracting the time limit of HTTP requests from
+// a legacy syncfile.
+var TimeoutPattern = regexp.MustCompile(`(?i)^[ \t]*timeout[ \t]*:[ \t]*(.+)`)
+
+// ConnectTimeoutPattern is used for extracting the time limit of connecting to
+// the Sync service from a legacy syncfile.
+var ConnectTimeoutPattern = regexp.MustCompile(`(?i)connecttimeout[ \t]*:[ \t]*(.+)`)
+
+// RetriesPattern is used for extracting the number of times failed requests
+// are retried from a legacy syncfile.
+var RetriesPattern = regexp.MustCompile(`(?i)retries[ \t]*:[ \t]*(.+)`)
+
+// ProxyPattern is used for extracting the proxy URL from a legacy syncfile.
+var ProxyPattern = regexp.MustCompile(`(?i)^[ \t]*proxy[ \t]*:[ \t]*(.+)`)
+
+// CABundlePattern is used for extracting the path of additional trusted
+// certificate authorities from a legacy syncfile.
+var CABundlePattern = regexp.MustCompile(`(?i)cabundle[ \t]*:[ \t]*(.+)`)
+
 // SeparatorPattern is used for finding the text/meta separator pipe in a
 // taskline.
 var SeparatorPattern = regexp.MustCompile(`[^\\]\|`)
 
-// CreationDatePattern is used for extracting the creation date from a
-// taskline.
-var CreationDatePattern = regexp.MustCompile(`(?i)creation:[\t ]*(\d{4}/\d{2}/\d{2}/\d{2}\/\d{2})`)
+// HashValuePattern is used for validating the task IDs in the taskline
+// metadata, either random (see NewID) or SHA-1 sums of the task text.
+var HashValuePattern = regexp.MustCompile(`(?i)^[a-f0-9]{40}$`)
 
-// FinishedDatePattern is used for extracting the date the task was marked as
-// finished from a taskline.
-var FinishedDatePattern = regexp.MustCompile(`(?i)finished:[\t ]*(\d{4}/\d{2}/\d{2}/\d{2}\/\d{2})`)
+// IDPrefixPattern is used for validating the task ID prefixes given in
+// selectors.
+var IDPrefixPattern = regexp.MustCompile(`(?i)^[a-f0-9]{4,40}$`)
 
-// HashPattern is used for extracting the SHA-1 sum of the task's text from a
-// taskline.
-var HashPattern = regexp.MustCompile(`(?i)id:[\t ]*([a-f0-9]{40})`)
+// AttributeKeyPattern is used for validating the keys of attributes set on the
+// command line.
+var AttributeKeyPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.\-]*$`)
+
+// SelectorSeparatorPattern is used for finding the first unescaped slash
+// separating a selector from a value.
+var SelectorSeparatorPattern = regexp.MustCompile(`[^\\]\/`)
+
+// AttributePlaceholderPattern is used for finding {attr:KEY} placeholders in
+// the output format.
+var AttributePlaceholderPattern = regexp.MustCompile(`\{attr:([^{}]+)\}`)
+
+// TagPattern is used for finding +tags and @contexts in the text of a task.
+// Tags have to start with a letter and be preceded by whitespace.
+var TagPattern = regexp.MustCompile(`(?:^|\s)([+@]\pL[\pL\pN_\-./:]*)`)
 
 // FullDateFormat specifies the general format for parsing strings to time
 // objects.
@@ -37,7 +96,7 @@ var FullDateFormat = "2006/01/02/15/04"
 var DateFormat = FullDateFormat[:10]
 
 // LastNetworkUpdateFormat specifies the format for parsing the
-// lastNetworkUpdate value into a time object.
+// lastNetworkUpdate value of legacy syncfiles and displaying it.
 var LastNetworkUpdateFormat = "2006/01/02/15/04/05"
 
 // DisplayTimeFormat specifies how a time object's time portion (H, M) should
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PayloadVersion is the version of the payload format written by tx. Payloads
+// without a version use the legacy format, which only holds the contents and
+// doneContents keys.
+const PayloadVersion = 1
+
+// Payload is the JSON object stored on the Sync service for a tasklist.
+type Payload struct {
+	Version      int        `json:"version"`
+	Device       string     `json:"device,omitempty"`    // The device ID (see Syncfile) of the uploader.
+	UpdatedAt    *time.Time `json:"updatedAt,omitempty"` // The time of the upload.
+	Contents     string     `json:"contents"`
+	DoneContents string     `json:"doneContents"`
+
+	// Extensions holds data added by other clients. tx does not interpret it,
+	// but keeps it intact when uploading.
+	Extensions map[string]json.RawMessage `json:"extensions,omitempty"`
+}
+
+// ParsePayload validates and decodes the data stored for a tasklist. Legacy
+// payloads are accepted and converted; unknown keys in them are ignored, as
+// they predate extensions.
+func ParsePayload(jsonData []byte) (payload Payload, err error) {
+	var keys map[string]json.RawMessage
+
+	if err := json.Unmarshal(jsonData, &keys); err != nil || keys == nil {
+		return payload, errors.New("not a JSON object")
+	}
+
+	if rawVersion, ok := keys["version"]; ok {
+		if payload.Version, err = parseVersion(rawVersion, PayloadVersion); err != nil {
+			return payload, err
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(jsonData))
+		decoder.DisallowUnknownFields()
+
+		if err := decoder.Decode(&payload); err != nil {
+			return payload, err
+		}
+	}
+
+	// Both versions require the contents to be strings, which the decoder
+	// would silently leave empty if they were null or missing.
+	for _, key := range []string{"contents", "doneContents"} {
+		raw, ok := keys[key]
+
+		if !ok {
+			return payload, fmt.Errorf("missing \"%s\"", key)
+		}
+
+		var value string
+
+		if err := json.Unmarshal(raw, &value); err != nil || string(raw) == "null" {
+			return payload, fmt.Errorf("\"%s\" is not a string", key)
+		}
+
+		if key == "contents" {
+			payload.Contents = value
+		} else {
+			payload.DoneContents = value
+		}
+	}
+
+	return payload, nil
+}
+
+// parseVersion decodes the version of a versioned JSON object, rejecting
+// versions newer than the supported one.
+func parseVersion(rawVersion json.RawMessage, supported int) (version int, err error) {
+	if err := json.Unmarshal(rawVersion, &version); err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid version %s", rawVersion)
+	}
+
+	if version > supported {
+		return 0, fmt.Errorf("version %d is not supported (up to %d), please update tx", version, supported)
+	}
+
+	return version, nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParsePayload(t *testing.T) {
+	t.Run("legacy", func(t *testing.T) {
+		payload, err := ParsePayload([]byte(`{"contents": "A\n", "doneContents": "B\n", "other": 1}`))
+
+		AssertEqual(t, err, nil, "Legacy payload was rejected")
+		AssertEqual(t, payload.Version, 0, "Legacy payload has a version")
+		AssertEqual(t, payload.Contents, "A\n", "Contents do not match")
+		AssertEqual(t, payload.DoneContents, "B\n", "Done contents do not match")
+	})
+
+	t.Run("versioned", func(t *testing.T) {
+		payload, err := ParsePayload([]byte(`{
+			"version": 1,
+			"device": "abc",
+			"updatedAt": "2024-05-06T07:08:09Z",
+			"contents": "A\n",
+			"doneContents": "",
+			"extensions": {"example.com/color": "red"}
+		}`))
+
+		AssertEqual(t, err, nil, "Versioned payload was rejected")
+		AssertEqual(t, payload.Device, "abc", "Device does not match")
+		AssertEqual(t, payload.UpdatedAt.Year(), 2024, "Update time does not match")
+		AssertEqual(t, string(payload.Extensions["example.com/color"]), `"red"`, "Extension does not match")
+	})
+
+	invalid := map[string]string{
+		"not_json":         `<html>`,
+		"not_object":       `["contents"]`,
+		"null":             `null`,
+		"missing_contents": `{"doneContents": ""}`,
+		"missing_done":     `{"contents": ""}`,
+		"number_contents":  `{"contents": 1, "doneContents": ""}`,
+		"null_contents":    `{"contents": null, "doneContents": ""}`,
+		"unknown_key":      `{"version": 1, "contents": "", "doneContents": "", "other": 1}`,
+		"invalid_version":  `{"version": "1", "contents": "", "doneContents": ""}`,
+		"newer_version":    `{"version": 2, "contents": "", "doneContents": ""}`,
+		"invalid_date":     `{"version": 1, "updatedAt": "yesterday", "contents": "", "doneContents": ""}`,
+	}
+
+	for name, data := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParsePayload([]byte(data))
+
+			AssertNotEqual(t, err, nil, "Invalid payload was accepted: "+data)
+		})
+	}
+}
+
+func TestPayloadSync(t *testing.T) {
+	t.Run("extensions", func(t *testing.T) {
+		vs := &versionedStorage{body: `{"version": 1, "contents": "", "doneContents": "", "extensions": {"example.com/color": "red"}}`}
+		ts := httptest.NewServer(vs)
+		defer ts.Close()
+
+		InitTestingSyncEnv(t, ts.URL)
+
+		add("A")
+		ListManager.Save()
+
+		payload, err := ParsePayload([]byte(vs.body))
+
+		AssertEqual(t, err, nil, "Uploaded payload is invalid")
+		AssertEqual(t, payload.Version, PayloadVersion, "Uploaded payload has the wrong version")
+		AssertEqual(t, payload.Device, ListManager.deviceID, "Uploaded payload has the wrong device")
+		AssertEqual(t, payload.UpdatedAt != nil, true, "Uploaded payload has no update time")
+		AssertEqual(t, string(payload.Extensions["example.com/color"]), `"red"`, "Extension was not kept")
+		AssertEqual(t, strings.Contains(payload.Contents, "A |"), true, "Task was not uploaded")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		vs := &versionedStorage{body: `{"contents": 42}`}
+		ts := httptest.NewServer(vs)
+		defer ts.Close()
+
+		InitTestingSyncEnv(t, ts.URL)
+
+		AssertEqual(t, ListManager.source, Local, "Invalid payload was not rejected")
+	})
+}
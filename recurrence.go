@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RecurWeekday is the recurrence unit of tasks repeating on every weekday
+// (Monday to Friday).
+const RecurWeekday = "weekday"
+
+// recurrenceKeywords maps the named recurrence rules to their interval.
+var recurrenceKeywords = map[string]string{
+	"daily":   "1d",
+	"weekly":  "1w",
+	"monthly": "1m",
+	"yearly":  "1y",
+}
+
+// recurrenceRulePattern matches recurrence rules like "2w" or "+monthly".
+var recurrenceRulePattern = regexp.MustCompile(`^(\+?)(?:(\d+)([dwmy])|(` + RecurWeekday + `))$`)
+
+// Recurrence describes how often a recurring task repeats.
+type Recurrence struct {
+	N     int    // The number of units between instances.
+	Unit  string // One of "d", "w", "m", "y" or RecurWeekday.
+	Fixed bool   // Repeat on a fixed schedule instead of after completion.
+}
+
+// ParseRecurrence parses a recurrence rule: an interval (e.g.: "1w", "3d",
+// "2m", "1y"), "daily", "weekly", "monthly", "yearly" or "weekday". By
+// default, the next instance is due one interval after the task was finished.
+// Rules prefixed with "+" follow a fixed schedule based on the due date
+// instead.
+func ParseRecurrence(rule string) (r Recurrence, err error) {
+	rule = strings.ToLower(strings.TrimSpace(rule))
+	fixed := strings.HasPrefix(rule, "+")
+
+	if interval, ok := recurrenceKeywords[strings.TrimPrefix(rule, "+")]; ok {
+		rule = interval
+
+		if fixed {
+			rule = "+" + rule
+		}
+	}
+
+	m := recurrenceRulePattern.FindStringSubmatch(rule)
+
+	if m == nil {
+		return r, fmt.Errorf("invalid recurrence \"%s\"", rule)
+	}
+
+	r.Fixed = m[1] == "+"
+
+	if m[4] != "" {
+		r.N, r.Unit = 1, RecurWeekday
+
+		return
+	}
+
+	r.N, _ = strconv.Atoi(m[2])
+	r.Unit = m[3]
+
+	if r.N < 1 {
+		return r, fmt.Errorf("invalid recurrence \"%s\"", rule)
+	}
+
+	return
+}
+
+// advance returns the date k intervals after base.
+func (r Recurrence) advance(base time.Time, k int) time.Time {
+	switch r.Unit {
+	case "d":
+		return base.AddDate(0, 0, k*r.N)
+	case "w":
+		return base.AddDate(0, 0, 7*k*r.N)
+	case "m":
+		return addMonths(base, k*r.N)
+	case "y":
+		return addMonths(base, 12*k*r.N)
+	}
+
+	date := base
+
+	for i := 0; i < k; i++ {
+		date = date.AddDate(0, 0, 1)
+
+		for date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+			date = date.AddDate(0, 0, 1)
+		}
+	}
+
+	return date
+}
+
+// Next returns the due date of the instance following a task with the
+// provided due date which was finished at the provided time. The time of day
+// of the due date is kept; tasks without one are due at the end of the day.
+// Fixed schedules skip instances which would already be due when finishing.
+func (r Recurrence) Next(due time.Time, finished time.Time) time.Time {
+	base := finished
+
+	if r.Fixed && !due.IsZero() {
+		base = due
+	}
+
+	hour, minute := 23, 59
+
+	if !due.IsZero() {
+		hour, minute = due.Hour(), due.Minute()
+	}
+
+	base = time.Date(base.Year(), base.Month(), base.Day(), hour, minute, 0, 0, time.UTC)
+
+	for k := 1; ; k++ {
+		next := r.advance(base, k)
+
+		if !r.Fixed || next.After(finished) {
+			return next
+		}
+	}
+}
+
+// addMonths adds months to a date, moving days which do not exist in the
+// resulting month (e.g.: the 31st) to the last day of the month.
+func addMonths(date time.Time, n int) time.Time {
+	first := time.Date(date.Year(), date.Month()+time.Month(n), 1, date.Hour(), date.Minute(), 0, 0, time.UTC)
+	lastDay := first.AddDate(0, 1, -1).Day()
+
+	return first.AddDate(0, 0, min(date.Day(), lastDay)-1)
+}
+
+// NextInstance creates the task replacing a finished recurring task. It keeps
+// the text and metadata of the task, with a new ID, its due date advanced and
+// without the time tracked for it or a snooze.
+func (t Task) NextInstance(finished time.Time) (next Task, err error) {
+	r, err := ParseRecurrence(t.recurrence)
+
+	if err != nil {
+		return
+	}
+
+	next = t
+	next.hash = NewID()
+	next.creationDate = finished
+	next.finishedDate = time.Unix(0, 0)
+	next.due = r.Next(t.due, finished)
+	next.tracked = nil
+	next.started = time.Time{}
+	next.wait = time.Time{}
+
+	return
+}
+
+// NormalizeRecurrence validates a recurrence rule given on the command line
+// and converts it to the form stored in the taskfile. The rules "-" and
+// "none" remove the recurrence.
+func NormalizeRecurrence(rule string) (string, error) {
+	rule = strings.ToLower(strings.TrimSpace(rule))
+
+	if rule == "-" || rule == "none" {
+		return "", nil
+	}
+
+	if _, err := ParseRecurrence(rule); err != nil {
+		return "", err
+	}
+
+	return rule, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Secret source prefixes understood by ReadSecret.
+const (
+	SecretEnv  = "env:"
+	SecretFile = "file:"
+	SecretCmd  = "cmd:"
+)
+
+// IsSecretSource reports whether a value refers to a secret source instead of
+// being a literal value.
+func IsSecretSource(value string) bool {
+	lower := strings.ToLower(strings.TrimSpace(value))
+
+	return strings.HasPrefix(lower, SecretEnv) || strings.HasPrefix(lower, SecretFile) || strings.HasPrefix(lower, SecretCmd)
+}
+
+// ReadSecret reads a secret from its source:
+//   - "env:NAME" reads the environment variable NAME,
+//   - "file:PATH" reads a file which must not be accessible by other users
+//     (e.g.: mode 0600),
+//   - "cmd:COMMAND" runs a command using sh (e.g.: "cmd:pass show tx") and
+//     uses its output. Arguments may be quoted as in the shell.
+//
+// Surrounding whitespace is trimmed from file contents and command output.
+func ReadSecret(source string) (string, error) {
+	kind, value, _ := strings.Cut(strings.TrimSpace(source), ":")
+	value = strings.TrimSpace(value)
+
+	var secret string
+
+	switch strings.ToLower(kind) + ":" {
+	case SecretEnv:
+		env, ok := os.LookupEnv(value)
+
+		if !ok {
+			return "", errors.New("environment variable is not set")
+		}
+
+		secret = env
+	case SecretFile:
+		info, err := os.Stat(value)
+
+		if err != nil {
+			return "", err
+		}
+
+		if info.Mode().Perm()&0077 != 0 {
+			return "", fmt.Errorf("permissions %#o are too open, use 0600", info.Mode().Perm())
+		}
+
+		contents, err := os.ReadFile(value)
+
+		if err != nil {
+			return "", err
+		}
+
+		secret = strings.TrimSpace(string(contents))
+	case SecretCmd:
+		if value == "" {
+			return "", errors.New("no command specified")
+		}
+
+		command := exec.Command("sh", "-c", value)
+		command.Stderr = os.Stderr
+
+		output, err := command.Output()
+
+		if err != nil {
+			return "", err
+		}
+
+		secret = strings.TrimSpace(string(output))
+	default:
+		return "", errors.New("use env:NAME, file:PATH or cmd:COMMAND")
+	}
+
+	if secret == "" {
+		return "", errors.New("secret is empty")
+	}
+
+	return secret, nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxBlobSize is the largest tasklist accepted by the built-in Sync service.
+const MaxBlobSize = 10 << 20
+
+// syncIDFormat restricts Sync IDs so they can be used as filenames safely.
+var syncIDFormat = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ServeParams holds the command line arguments for the `serve` subcommand.
+type ServeParams struct {
+	Listen   string `short:"l" long:"listen" description:"The address to listen on" value-name:"ADDR" default:"localhost:8080"`
+	Storage  string `short:"s" long:"storage" description:"The directory to store tasklists in" value-name:"DIR" default:"tx-sync"`
+	NoDelete bool   `long:"no-delete" description:"Reject requests to delete tasklists"`
+	Token    string `long:"token" description:"Require a bearer token read from env:NAME, file:PATH (mode 0600) or cmd:COMMAND" value-name:"SOURCE"`
+}
+
+// Execute uses the provided ServeParams and runs the built-in Sync service
+// until it is stopped.
+func (a *ServeParams) Execute(args []string) error {
+	server := NewSyncServer(a.Storage, !a.NoDelete)
+
+	if a.Token != "" {
+		token, err := ReadSecret(a.Token)
+
+		if err != nil {
+			Error(ErrAuthSecret, a.Token, err)
+		}
+
+		server.token = token
+	}
+
+	log.Printf("Serving tasklists from \"%s\" on http://%s/", a.Storage, a.Listen)
+
+	if err := http.ListenAndServe(a.Listen, server); err != nil {
+		Error(ErrServerListen, a.Listen, err)
+	}
+
+	return nil
+}
+
+// SyncServer is a Sync service implementing the JSON Blob-compatible
+// protocol spoken by tx. Tasklists are stored as files in a directory.
+type SyncServer struct {
+	storage     string
+	allowDelete bool
+	token       string // If set, requests must carry it as a bearer token.
+	mutex       sync.Mutex
+}
+
+// NewSyncServer creates a SyncServer storing tasklists in the provided
+// directory, creating it if needed.
+func NewSyncServer(storage string, allowDelete bool) *SyncServer {
+	if err := os.MkdirAll(storage, 0700); err != nil {
+		Error(ErrServerStorage, storage, err)
+	}
+
+	return &SyncServer{storage: storage, allowDelete: allowDelete}
+}
+
+// ServeHTTP handles a single request and logs it.
+func (s *SyncServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	start := time.Now()
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+	s.mutex.Lock()
+	s.handle(recorder, req)
+	s.mutex.Unlock()
+
+	log.Printf("%s %s %s %d %s", req.RemoteAddr, req.Method, req.URL.Path, recorder.status, time.Since(start).Round(time.Millisecond))
+}
+
+// handle dispatches a request: POST on the root creates a tasklist,
+// GET/PUT/DELETE on "/{syncID}" operate on an existing one.
+func (s *SyncServer) handle(w http.ResponseWriter, req *http.Request) {
+	if s.token != "" {
+		given := []byte(req.Header.Get("Authorization"))
+
+		if subtle.ConstantTimeCompare(given, []byte("Bearer "+s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="tx"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	syncID := strings.Trim(req.URL.Path, "/")
+
+	if syncID == "" {
+		if req.Method != "POST" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		s.create(w, req)
+		return
+	}
+
+	if !syncIDFormat.MatchString(syncID) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	path := filepath.Join(s.storage, syncID+".json")
+	data, err := os.ReadFile(path)
+
+	if os.IsNotExist(err) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	switch req.Method {
+	case "GET":
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", blobETag(data))
+		w.Write(data)
+	case "PUT":
+		if ifMatch := req.Header.Get("If-Match"); ifMatch != "" && ifMatch != blobETag(data) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, MaxBlobSize))
+
+		if err != nil {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		if err := s.write(path, body); err != nil {
+			s.fail(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", blobETag(body))
+		w.Write(body)
+	case "DELETE":
+		if !s.allowDelete {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err := os.Remove(path); err != nil {
+			s.fail(w, err)
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// create stores a new tasklist under a random Sync ID and points to it in the
+// Location header.
+func (s *SyncServer) create(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, MaxBlobSize))
+
+	if err != nil {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if len(body) == 0 {
+		body = []byte(`{"contents": "", "doneContents": ""}`)
+	}
+
+	buf := make([]byte, 16)
+	rand.Read(buf)
+
+	syncID := fmt.Sprintf("%x", buf)
+
+	if err := s.write(filepath.Join(s.storage, syncID+".json"), body); err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	w.Header().Set("Location", strings.TrimSuffix(req.URL.Path, "/")+"/"+syncID)
+	w.Header().Set("ETag", blobETag(body))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// write replaces a stored tasklist atomically.
+func (s *SyncServer) write(path string, data []byte) error {
+	tmp, err := os.CreateTemp(s.storage, ".upload-")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// fail logs an internal error and responds with 500.
+func (s *SyncServer) fail(w http.ResponseWriter, err error) {
+	log.Printf("Storage error: %v", err)
+	w.WriteHeader(http.StatusInternalServerError)
+}
+
+// blobETag returns the entity tag of a stored tasklist: its quoted SHA-1 sum.
+func blobETag(data []byte) string {
+	return `"` + hexHash(string(data)) + `"`
+}
+
+// statusRecorder remembers the status code written to a ResponseWriter.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// init gets called when the package is imported; adds the subcommand to the
+// global argument parser.
+func init() {
+	var params ServeParams
+	GlobalParser.AddCommand("serve", "Run a self-hosted Sync service", "", &params)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSyncServer(t *testing.T) {
+	ts := httptest.NewServer(NewSyncServer(t.TempDir(), true))
+	defer ts.Close()
+
+	backend := NewJSONBlobBackend(ts.URL+"/", SyncAuth{})
+
+	syncID, err := backend.Create()
+	AssertEqual(t, err, nil, "Could not create tasklist")
+
+	_, etag, err := backend.Fetch(syncID)
+	AssertEqual(t, err, nil, "Could not fetch created tasklist")
+
+	newETag, err := backend.Store(syncID, []byte(`{"contents": "A", "doneContents": ""}`), etag)
+	AssertEqual(t, err, nil, "Could not store tasklist")
+
+	_, err = backend.Store(syncID, []byte(`{"contents": "B", "doneContents": ""}`), etag)
+	AssertEqual(t, err, errPreconditionFailed, "Outdated upload was not rejected")
+
+	data, fetchedETag, _ := backend.Fetch(syncID)
+	AssertEqual(t, string(data), `{"contents": "A", "doneContents": ""}`, "Stored tasklist does not match")
+	AssertEqual(t, fetchedETag, newETag, "ETag of stored tasklist does not match")
+
+	for _, path := range []string{"/unknown", "/../secret"} {
+		res, err := http.Get(ts.URL + path)
+		AssertEqual(t, err, nil, "Request failed")
+		res.Body.Close()
+		AssertEqual(t, res.StatusCode, http.StatusNotFound, "Unknown tasklist was found")
+	}
+
+	AssertEqual(t, backend.Delete(syncID), nil, "Could not delete tasklist")
+
+	_, _, err = backend.Fetch(syncID)
+	AssertEqual(t, err != nil, true, "Deleted tasklist can still be fetched")
+
+	t.Run("no_delete", func(t *testing.T) {
+		ts := httptest.NewServer(NewSyncServer(t.TempDir(), false))
+		defer ts.Close()
+
+		res, _ := http.Post(ts.URL+"/", "application/json", strings.NewReader("{}"))
+		res.Body.Close()
+
+		req, _ := http.NewRequest("DELETE", ts.URL+res.Header.Get("Location"), nil)
+		res, _ = http.DefaultClient.Do(req)
+		res.Body.Close()
+
+		AssertEqual(t, res.StatusCode, http.StatusMethodNotAllowed, "Deletion was not rejected")
+	})
+
+	t.Run("syncing", func(t *testing.T) {
+		ts := httptest.NewServer(NewSyncServer(t.TempDir(), true))
+		defer ts.Close()
+
+		InitTestingPathVariables(t)
+		enable(ts.URL+"/", "")
+
+		MainList = &Tasklist{}
+		DoneList = &Tasklist{}
+		ListManager = &TasklistManager{}
+		ListManager.EnsureInitialized(MainList)
+
+		add("served")
+		ListManager.Save()
+
+		res, err := http.Get(ts.URL + "/" + ListManager.syncID)
+		AssertEqual(t, err, nil, "Request failed")
+		defer res.Body.Close()
+
+		body, _ := io.ReadAll(res.Body)
+
+		if !strings.Contains(string(body), "served |") {
+			t.Fatalf("Uploaded tasklist is missing the added task:\n%s", body)
+		}
+	})
+}
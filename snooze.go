@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Views of snoozed tasks accepted by Tasklist.Show.
+const (
+	HideSnoozed = "hide" // Hide snoozed tasks and print how many were hidden.
+	ShowSnoozed = "show" // List snoozed tasks along with the others.
+	OnlySnoozed = "only" // Only list snoozed tasks.
+)
+
+// IsSnoozed reports whether the task is hidden until a later date.
+func (t Task) IsSnoozed(now time.Time) bool {
+	return !t.wait.IsZero() && t.wait.After(now)
+}
+
+// snoozeFilter returns whether a task should be listed in a view of snoozed
+// tasks, and whether it is hidden because it is snoozed.
+func snoozeFilter(task Task, view string, now time.Time) (listed bool, hidden bool) {
+	snoozed := task.IsSnoozed(now)
+
+	switch view {
+	case HideSnoozed:
+		return !snoozed, snoozed
+	case OnlySnoozed:
+		return snoozed, false
+	}
+
+	return true, false
+}
+
+// printSnoozed prints the footer listing how many tasks were hidden.
+func printSnoozed(n int) {
+	if n > 0 {
+		fmt.Printf("%d snoozed\n", n)
+	}
+}
+
+// setSnooze hides the selected tasks and their subtasks until a date, or
+// wakes them up using "-".
+func setSnooze(cmd string) {
+	ListManager.EnsureInitialized(MainList)
+	exitOnEmptyTasks("Snooze")
+
+	// Dates may contain slashes themselves.
+	selector, value, ok := splitSelector(cmd)
+
+	if !ok {
+		Error(ErrInvalidSelector, "Snooze", cmd)
+	}
+
+	value = strings.TrimSpace(value)
+
+	var wait time.Time
+
+	if value != "-" && !strings.EqualFold(value, "none") {
+		var err error
+
+		if wait, err = ParseDateArg(value, false); err != nil {
+			Error(ErrInvalidDate, "Snooze", value)
+		}
+	}
+
+	indexes, err := MainList.SelectTasks(selector)
+
+	ExitOnSelectorError(err, "Snooze", selector)
+
+	for _, i := range MainList.Subtrees(indexes) {
+		task, ok := MainList.tasks[i]
+
+		if !ok {
+			Error(ErrInvalidIndex, "Snooze", i)
+		}
+
+		task.wait = wait
+
+		MainList.tasks[i] = task
+		ListManager.Record(JournalEdit, task)
+	}
+
+	MainList.MarkModified()
+}
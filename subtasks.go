@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SubtaskIndent is printed once per level before subtasks when listing, unless
+// the format contains the {depth} placeholder.
+const SubtaskIndent = "  "
+
+// tree returns the subtasks of every task in the tasklist along with the
+// top-level tasks, both in the provided order. Tasks whose parent is not in
+// the tasklist are treated as top-level tasks.
+func (tl *Tasklist) tree(order []int) (roots []int, children map[int][]int) {
+	children = make(map[int][]int)
+	hashes := make(map[string]int, len(tl.tasks))
+
+	// Identical tasks share a hash, subtasks belong to the first one.
+	for _, index := range tl.OrderKeys() {
+		if _, exists := hashes[tl.tasks[index].hash]; !exists {
+			hashes[tl.tasks[index].hash] = index
+		}
+	}
+
+	for _, index := range order {
+		parent, ok := hashes[tl.tasks[index].parent]
+
+		if ok && tl.tasks[index].parent != "" && parent != index {
+			children[parent] = append(children[parent], index)
+		} else {
+			roots = append(roots, index)
+		}
+	}
+
+	return
+}
+
+// TreeKeys orders the keys of the tasklist so every task is followed by its
+// subtasks. Siblings are listed in the requested order (see SortKeys). The
+// depth of every task is returned as well, top-level tasks having depth 0.
+func (tl *Tasklist) TreeKeys(order string) (keys []int, depths map[int]int) {
+	sorted := tl.SortKeys(order)
+	roots, children := tl.tree(sorted)
+
+	depths = make(map[int]int, len(sorted))
+
+	var walk func(index int, depth int)
+
+	walk = func(index int, depth int) {
+		if _, seen := depths[index]; seen {
+			return
+		}
+
+		depths[index] = depth
+		keys = append(keys, index)
+
+		for _, child := range children[index] {
+			walk(child, depth+1)
+		}
+	}
+
+	for _, index := range roots {
+		walk(index, 0)
+	}
+
+	// Tasks referencing each other as parents are not reachable from the
+	// top-level tasks, but still have to be listed.
+	for _, index := range sorted {
+		walk(index, 0)
+	}
+
+	return
+}
+
+// Subtrees extends a selection with the subtasks of the selected tasks,
+// recursively. Every index is only returned once and parents always precede
+// their subtasks.
+func (tl *Tasklist) Subtrees(indexes []int) (result []int) {
+	_, children := tl.tree(tl.OrderKeys())
+	seen := make(map[int]bool)
+
+	var walk func(index int)
+
+	walk = func(index int) {
+		if seen[index] {
+			return
+		}
+
+		seen[index] = true
+		result = append(result, index)
+
+		for _, child := range children[index] {
+			walk(child)
+		}
+	}
+
+	for _, index := range indexes {
+		walk(index)
+	}
+
+	return
+}
+
+// uniqueIndexes removes repeated indexes, keeping the first occurrence.
+func uniqueIndexes(indexes []int) (result []int) {
+	seen := make(map[int]bool)
+
+	for _, index := range indexes {
+		if !seen[index] {
+			seen[index] = true
+			result = append(result, index)
+		}
+	}
+
+	return
+}
+
+// Ancestors returns the indexes of the parent, grandparent, etc. of a task
+// which are in the same tasklist, starting with the top-most one.
+func (tl *Tasklist) Ancestors(index int) (ancestors []int) {
+	seen := map[int]bool{index: true}
+
+	for task := tl.tasks[index]; task.parent != ""; {
+		parent, ok := tl.FindHash(task.parent)
+
+		if !ok || seen[parent] {
+			break
+		}
+
+		seen[parent] = true
+		ancestors = append([]int{parent}, ancestors...)
+		task = tl.tasks[parent]
+	}
+
+	return
+}
+
+// Progress returns how many of the direct subtasks of a task are finished,
+// e.g.: "2/3", or an empty string if it has no subtasks.
+func Progress(task Task) string {
+	var total, finished int
+
+	for _, tl := range []*Tasklist{MainList, DoneList} {
+		for _, other := range tl.tasks {
+			if other.parent == task.hash {
+				total++
+
+				if tl == DoneList {
+					finished++
+				}
+			}
+		}
+	}
+
+	if total == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%d/%d", finished, total)
+}
+
+// addSub adds a new subtask to the task selected by the PARENT part of a
+// PARENT/TEXT argument.
+func addSub(cmd string) {
+	ListManager.EnsureInitialized(MainList)
+	exitOnEmptyTasks("Add Sub")
+
+	var parts []string
+	recursiveSplit(cmd, &parts)
+
+	// The text may contain slashes itself.
+	if len(parts) < 2 {
+		Error(ErrInvalidSelector, "Add Sub", cmd)
+	}
+
+	index := MainList.InterpretSelectorPart(parts[0], MainList.OrderKeys())
+	parent, exists := MainList.tasks[index]
+
+	if !exists {
+		Error(ErrInvalidIndex, "Add Sub", index)
+	}
+
+	task := createTask(strings.ReplaceAll(strings.Join(parts[1:], "/"), `\/`, `/`))
+	task.parent = parent.hash
+
+	MainList.Add(task)
+	ListManager.Record(JournalAdd, task)
+}
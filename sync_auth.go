@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Authentication schemes supported for HTTP Sync services.
+const (
+	AuthBearer = "bearer"
+	AuthBasic  = "basic"
+)
+
+// DefaultAuthSecret is the secret source used if the syncfile enables
+// authentication without specifying one.
+const DefaultAuthSecret = SecretEnv + "TX_SYNC_TOKEN"
+
+// SyncAuth holds the credentials sent to the Sync service, as configured in
+// the syncfile. Secrets are stored as sources (see ReadSecret) and only read
+// when a request is made.
+type SyncAuth struct {
+	Type    string   `json:"type,omitempty"`    // AuthBearer, AuthBasic or empty for no authentication.
+	User    string   `json:"user,omitempty"`    // The username used for basic authentication.
+	Secret  string   `json:"secret,omitempty"`  // The source of the token or password.
+	Headers []string `json:"headers,omitempty"` // Extra headers in "Name: value" form.
+}
+
+// ParseLine extracts an authentication setting from a syncfile line and
+// reports whether the line held one.
+func (a *SyncAuth) ParseLine(line string) bool {
+	if m := AuthTypePattern.FindStringSubmatch(line); len(m) == 2 {
+		a.Type = strings.ToLower(strings.TrimSpace(m[1]))
+		return true
+	}
+
+	if m := AuthUserPattern.FindStringSubmatch(line); len(m) == 2 {
+		a.User = strings.TrimSpace(m[1])
+		return true
+	}
+
+	if m := AuthSecretPattern.FindStringSubmatch(line); len(m) == 2 {
+		a.Secret = strings.TrimSpace(m[1])
+		return true
+	}
+
+	if m := HeaderPattern.FindStringSubmatch(line); len(m) == 2 {
+		a.Headers = append(a.Headers, strings.TrimSpace(m[1]))
+		return true
+	}
+
+	return false
+}
+
+// HTTPHeaders resolves the secrets and returns the headers to attach to every
+// request. Header values which refer to a secret source (e.g.:
+// "X-Api-Key: env:API_KEY") are read from it.
+func (a SyncAuth) HTTPHeaders() (http.Header, error) {
+	header := http.Header{}
+
+	for _, line := range a.Headers {
+		name, value, ok := strings.Cut(line, ":")
+
+		if !ok {
+			Warn("Ignoring invalid header \"%s\" in syncfile \"%s\"", line, SyncfilePath)
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+
+		if IsSecretSource(value) {
+			secret, err := ReadSecret(value)
+
+			if err != nil {
+				return nil, NewSyncError(ErrAuthSecret, value, err)
+			}
+
+			value = secret
+		}
+
+		header.Add(strings.TrimSpace(name), value)
+	}
+
+	if a.Type == "" {
+		return header, nil
+	}
+
+	source := a.Secret
+
+	if source == "" {
+		source = DefaultAuthSecret
+	}
+
+	secret, err := ReadSecret(source)
+
+	if err != nil {
+		return nil, NewSyncError(ErrAuthSecret, source, err)
+	}
+
+	switch a.Type {
+	case AuthBearer:
+		header.Set("Authorization", "Bearer "+secret)
+	case AuthBasic:
+		request := http.Request{Header: http.Header{}}
+		request.SetBasicAuth(a.User, secret)
+
+		header.Set("Authorization", request.Header.Get("Authorization"))
+	default:
+		Warn("Ignoring unknown authType \"%s\" in syncfile \"%s\"", a.Type, SyncfilePath)
+	}
+
+	return header, nil
+}
+
+// IsAuthError reports whether an error was caused by missing or rejected
+// credentials. These are fatal even when sync errors would otherwise only
+// cause a fallback to the local tasklist.
+func IsAuthError(err error) bool {
+	var syncErr *SyncError
+
+	if !errors.As(err, &syncErr) {
+		return false
+	}
+
+	return syncErr.Code == ErrAuthSecret || syncErr.Code == ErrUnauthorized || syncErr.Code == ErrForbidden
+}
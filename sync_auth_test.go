@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadSecret(t *testing.T) {
+	t.Setenv("TX_TESTING_SECRET", "from-env")
+
+	dir := t.TempDir()
+	private := filepath.Join(dir, "private")
+	public := filepath.Join(dir, "public")
+
+	os.WriteFile(private, []byte("from-file\n"), 0600)
+	os.WriteFile(public, []byte("from-file\n"), 0644)
+
+	for source, expected := range map[string]string{
+		"env:TX_TESTING_SECRET": "from-env",
+		"file:" + private:       "from-file",
+		"cmd:echo from-cmd":     "from-cmd",
+		`cmd:echo "my  key"`:    "my  key",
+	} {
+		secret, err := ReadSecret(source)
+
+		AssertEqual(t, err, nil, "Could not read secret from "+source)
+		AssertEqual(t, secret, expected, "Secret read from "+source+" does not match")
+	}
+
+	for _, source := range []string{"file:" + public, "env:TX_TESTING_UNSET", "cmd:false", "plain"} {
+		if _, err := ReadSecret(source); err == nil {
+			t.Errorf("Reading secret from %s did not fail", source)
+		}
+	}
+}
+
+func TestSyncAuth(t *testing.T) {
+	t.Setenv("TX_TESTING_TOKEN", "s3cr3t")
+	t.Setenv("TX_TESTING_KEY", "k3y")
+
+	auth := SyncAuth{}
+
+	for _, line := range []string{"syncID: ignored", "authType: Bearer", "authSecret: env:TX_TESTING_TOKEN", "header: X-Api-Key: env:TX_TESTING_KEY", "header: X-Client: tx"} {
+		auth.ParseLine(line)
+	}
+
+	header, err := auth.HTTPHeaders()
+
+	AssertEqual(t, err, nil, "Could not build authentication headers")
+	AssertEqual(t, header.Get("Authorization"), "Bearer s3cr3t", "Bearer token does not match")
+	AssertEqual(t, header.Get("X-Api-Key"), "k3y", "Secret header does not match")
+	AssertEqual(t, header.Get("X-Client"), "tx", "Literal header does not match")
+
+	header, _ = SyncAuth{Type: AuthBasic, User: "user", Secret: "env:TX_TESTING_TOKEN"}.HTTPHeaders()
+
+	AssertEqual(t, header.Get("Authorization"), "Basic dXNlcjpzM2NyM3Q=", "Basic auth header does not match")
+
+	t.Run("syncing", func(t *testing.T) {
+		server := NewSyncServer(t.TempDir(), true)
+		server.token = "s3cr3t"
+
+		ts := httptest.NewServer(server)
+		defer ts.Close()
+
+		InitTestingPathVariables(t)
+
+		params := EnableParams{URL: ts.URL, Auth: AuthBearer, AuthSecret: "env:TX_TESTING_TOKEN"}
+		params.Execute([]string{})
+
+		MainList = &Tasklist{}
+		DoneList = &Tasklist{}
+		ListManager = &TasklistManager{}
+		ListManager.EnsureInitialized(MainList)
+
+		AssertEqual(t, ListManager.source, Network, "Tasklist was not loaded from the authenticated Sync service")
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		AssertExitError(t, "TestSyncAuth/unauthorized", ErrUnauthorized, func() {
+			server := NewSyncServer(t.TempDir(), true)
+			server.token = "s3cr3t"
+
+			ts := httptest.NewServer(server)
+			defer ts.Close()
+
+			InitTestingSyncEnv(t, ts.URL)
+		})
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		AssertExitError(t, "TestSyncAuth/forbidden", ErrForbidden, func() {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusForbidden)
+			}))
+			defer ts.Close()
+
+			InitTestingSyncEnv(t, ts.URL)
+		})
+	})
+}
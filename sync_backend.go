@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// SyncBackend transports serialized tasklists to and from a Sync service.
+// Implementations are selected by the scheme of the Sync URL.
+type SyncBackend interface {
+	// Create reserves a new Sync ID on the Sync service.
+	Create() (syncID string, err error)
+	// Fetch downloads the data stored under a Sync ID along with its entity
+	// tag.
+	Fetch(syncID string) (data []byte, etag string, err error)
+	// Store uploads data under a Sync ID and returns its new entity tag. If
+	// etag is not empty, the upload must fail with errPreconditionFailed when
+	// the stored data has changed since it was fetched.
+	Store(syncID string, data []byte, etag string) (newETag string, err error)
+	// Delete removes the data stored under a Sync ID.
+	Delete(syncID string) error
+}
+
+// SyncBackendFactory creates a SyncBackend for the provided Sync URL and
+// credentials.
+type SyncBackendFactory func(syncURL string, auth SyncAuth) SyncBackend
+
+// syncBackends maps lowercase URL schemes to their backends.
+var syncBackends = map[string]SyncBackendFactory{}
+
+// errPreconditionFailed is returned by SyncBackend.Store when the stored data
+// has changed since it was fetched.
+var errPreconditionFailed = errors.New("precondition failed")
+
+// SyncError is returned by sync backends when an operation fails. It carries
+// the exit code and message arguments used if the error turns out to be
+// fatal.
+type SyncError struct {
+	Code int
+	Args []interface{}
+}
+
+// NewSyncError creates a SyncError from an exit code and its message
+// arguments.
+func NewSyncError(code int, args ...interface{}) *SyncError {
+	return &SyncError{Code: code, Args: args}
+}
+
+func (e *SyncError) Error() string {
+	return fmt.Sprintf(errorMessages[e.Code], e.Args...)
+}
+
+// ExitOnSyncError prints the error message of a failed sync operation and
+// exits. Does nothing if err is nil.
+func ExitOnSyncError(err error, syncURL string) {
+	if err == nil {
+		return
+	}
+
+	var syncErr *SyncError
+
+	if errors.As(err, &syncErr) {
+		Error(syncErr.Code, syncErr.Args...)
+	}
+
+	Error(ErrInvalidResponse, syncURL, err)
+}
+
+// RegisterSyncBackend makes a backend available for Sync URLs with the
+// provided scheme.
+func RegisterSyncBackend(scheme string, factory SyncBackendFactory) {
+	syncBackends[strings.ToLower(scheme)] = factory
+}
+
+// NewSyncBackend creates the backend responsible for the provided Sync URL.
+func NewSyncBackend(syncURL string, auth SyncAuth) SyncBackend {
+	parsedURL, err := url.Parse(syncURL)
+
+	if err != nil {
+		Error(ErrUnsupportedBackend, syncURL)
+	}
+
+	factory, ok := syncBackends[strings.ToLower(parsedURL.Scheme)]
+
+	if !ok {
+		Error(ErrUnsupportedBackend, syncURL)
+	}
+
+	return factory(syncURL, auth)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// memoryBackend is a SyncBackend which keeps tasklists in memory.
+type memoryBackend struct {
+	blobs map[string][]byte
+}
+
+var memoryStorage = &memoryBackend{blobs: map[string][]byte{}}
+
+func (b *memoryBackend) Create() (string, error) {
+	return "memory-sync-id", nil
+}
+
+func (b *memoryBackend) Fetch(syncID string) ([]byte, string, error) {
+	data, ok := b.blobs[syncID]
+
+	if !ok {
+		return nil, "", NewSyncError(ErrNoSyncID, syncID)
+	}
+
+	return data, `"` + hexHash(string(data)) + `"`, nil
+}
+
+func (b *memoryBackend) Store(syncID string, data []byte, etag string) (string, error) {
+	if etag != "" {
+		if _, current, _ := b.Fetch(syncID); current != etag {
+			return "", errPreconditionFailed
+		}
+	}
+
+	b.blobs[syncID] = data
+
+	return `"` + hexHash(string(data)) + `"`, nil
+}
+
+func (b *memoryBackend) Delete(syncID string) error {
+	delete(b.blobs, syncID)
+
+	return nil
+}
+
+func TestSyncBackend(t *testing.T) {
+	RegisterSyncBackend("memory", func(syncURL string, auth SyncAuth) SyncBackend {
+		return memoryStorage
+	})
+
+	t.Run("custom_scheme", func(t *testing.T) {
+		memoryStorage.blobs["testing-sync-id"] = []byte(`{"contents": "", "doneContents": ""}`)
+
+		InitTestingSyncEnv(t, "MEMORY://storage")
+
+		AssertEqual(t, ListManager.source, Network, "Tasklist was not loaded from the memory backend")
+
+		add("stored in memory")
+		ListManager.Save()
+
+		if !strings.Contains(string(memoryStorage.blobs["testing-sync-id"]), "stored in memory") {
+			t.Fatal("Tasklist was not stored in the memory backend")
+		}
+	})
+
+	t.Run("unsupported_scheme", func(t *testing.T) {
+		AssertExitError(t, "TestSyncBackend/unsupported_scheme", ErrUnsupportedBackend, func() {
+			NewSyncBackend("gopher://example.com/", SyncAuth{})
+		})
+	})
+}
+
+func TestJSONBlobWithoutETags(t *testing.T) {
+	stored := "first"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case "GET":
+			io.WriteString(w, stored)
+		case "PUT":
+			data, _ := io.ReadAll(req.Body)
+			stored = string(data)
+		}
+	}))
+	defer server.Close()
+
+	backend := NewJSONBlobBackend(server.URL, SyncAuth{})
+
+	_, etag, err := backend.Fetch("testing-sync-id")
+	AssertEqual(t, err, nil, "Tasklist could not be fetched")
+
+	stored = "uploaded by another device"
+
+	_, err = backend.Store("testing-sync-id", []byte("second"), etag)
+	AssertEqual(t, err, errPreconditionFailed, "Outdated upload was not rejected")
+	AssertEqual(t, stored, "uploaded by another device", "Outdated upload replaced the tasklist")
+
+	_, etag, _ = backend.Fetch("testing-sync-id")
+
+	_, err = backend.Store("testing-sync-id", []byte("second"), etag)
+	AssertEqual(t, err, nil, "Up to date upload was rejected")
+	AssertEqual(t, stored, "second", "Up to date upload was not stored")
+}
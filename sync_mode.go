@@ -1,10 +1,11 @@
 package main
 
 import (
-	"bufio"
 	"fmt"
-	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
 // SyncActions contains all options and positional arguments for syncfile
@@ -15,12 +16,22 @@ type SyncActions struct {
 	Free    FreeParams    `command:"free" description:"Permanently deactivate a Sync ID. The tasklist it belongs to will be deleted from the Sync service."`
 	Now     NowParams     `command:"now" description:"Uploads the local tasklist to the configured Sync service, even if the file is outdated."`
 	Switch  SwitchParams  `command:"switch" description:"Change the Sync service to be used for this tasklist."`
+	Pull    PullParams    `command:"pull" description:"Download the synced tasklist and overwrite the local one. The local taskfiles are backed up first."`
+	Status  StatusParams  `command:"status" description:"Show whether the local and the synced tasklist have diverged. Exits with 0 (in sync), 100 (local ahead), 101 (remote ahead) or 102 (diverged)."`
 }
 
 // EnableParams holds the command line arguments for the `sync enable` subcommand.
 type EnableParams struct {
-	URL  string `short:"u" long:"sync-url" description:"The Sync Service to request the Sync ID from. If unspecified, the global fallback Sync URL will be used."`
-	Args struct {
+	URL        string   `short:"u" long:"sync-url" description:"The Sync Service to request the Sync ID from. If unspecified, the global fallback Sync URL will be used."`
+	Encrypt    bool     `short:"e" long:"encrypt" description:"Encrypt the tasklist before uploading it, so the Sync service only sees an opaque blob."`
+	KeyEnv     string   `long:"key-env" description:"The environment variable holding the encryption passphrase. Defaults to TX_SYNC_PASSPHRASE." value-name:"NAME"`
+	KeyFile    string   `long:"key-file" description:"The file holding the encryption key. Overrides --key-env." value-name:"PATH"`
+	Auth       string   `long:"auth" description:"Authenticate with the Sync service using a bearer token or basic auth." choice:"bearer" choice:"basic"`
+	AuthUser   string   `long:"auth-user" description:"The username used for basic auth." value-name:"NAME"`
+	AuthSecret string   `long:"auth-secret" description:"Where to read the token or password from: env:NAME, file:PATH (mode 0600) or cmd:COMMAND. Defaults to env:TX_SYNC_TOKEN." value-name:"SOURCE"`
+	Headers    []string `long:"header" description:"An extra header sent with every request, e.g. \"X-Api-Key: env:API_KEY\". Can be repeated." value-name:"HEADER"`
+	Workspace  string   `short:"w" long:"workspace" description:"Sync every taskfile in a directory under a single Sync ID, including lists created or deleted on other devices." value-name:"DIR"`
+	Args       struct {
 		SyncID string `description:"The Sync ID to pair with the tasklist. If unspecified, a new Sync ID will be requested from the provided Sync Service."`
 	} `positional-args:"true"`
 }
@@ -39,6 +50,83 @@ func (a *EnableParams) Execute(args []string) error {
 
 	EnsureTrailingSlash(&syncURL)
 
+	// Workspaces have their own syncfile in the synced directory.
+	syncfilePath := SyncfilePath
+
+	if a.Workspace != "" {
+		if err := os.MkdirAll(a.Workspace, 0755); err != nil {
+			Error(ErrWorkspaceRead, a.Workspace, err)
+		}
+
+		syncfilePath = WorkspaceSyncfilePath(a.Workspace)
+	}
+
+	var encryption *SyncfileEncryption
+
+	if a.Encrypt {
+		keySource := "env:" + DefaultKeyEnv
+
+		if a.KeyFile != "" {
+			keyFile, err := filepath.Abs(a.KeyFile)
+
+			if err != nil {
+				Error(ErrEncryptionKey, a.KeyFile, err)
+			}
+
+			keySource = "file:" + keyFile
+		} else if a.KeyEnv != "" {
+			keySource = "env:" + a.KeyEnv
+		}
+
+		// Make sure the key is available before anything is uploaded.
+		ReadEncryptionSecret(keySource)
+
+		encryption = &SyncfileEncryption{Scheme: EncryptionAES256GCM, KeySource: keySource}
+	}
+
+	configure := func(sf *Syncfile) {
+		if encryption != nil {
+			// The tasklist stays encrypted on the Sync service when only the
+			// key source changes.
+			encryption.Uploaded = sf.Encryption != nil && sf.Encryption.Uploaded
+			sf.Encryption = encryption
+		}
+
+		if a.Auth != "" || len(a.Headers) > 0 {
+			if sf.Auth == nil {
+				sf.Auth = &SyncAuth{}
+			}
+		}
+
+		if a.Auth != "" {
+			sf.Auth.Type = a.Auth
+			sf.Auth.Secret = a.AuthSecret
+
+			if sf.Auth.Secret == "" {
+				sf.Auth.Secret = DefaultAuthSecret
+			}
+
+			if a.AuthUser != "" {
+				sf.Auth.User = a.AuthUser
+			}
+		}
+
+		if len(a.Headers) > 0 {
+			sf.Auth.Headers = nil
+
+			for _, header := range a.Headers {
+				sf.Auth.Headers = append(sf.Auth.Headers, strings.TrimSpace(header))
+			}
+		}
+	}
+
+	if a.Workspace != "" {
+		enableWorkspace(a.Workspace, syncURL, a.Args.SyncID, configure)
+		return nil
+	}
+
+	UpdateSyncfile(syncfilePath, configure)
+
 	enable(syncURL, a.Args.SyncID)
 
 	return nil
@@ -52,8 +140,7 @@ type DisableParams struct{}
 func (a *DisableParams) Execute(args []string) error {
 	InitPathVariables(ConfigOptions.List)
 
-	ReplaceOrAppendSyncfileLine(SyncIDPattern, "")
-	ReplaceOrAppendSyncfileLine(SyncURLPattern, "")
+	UpdateSyncfile(SyncfilePath, (*Syncfile).Disable)
 
 	return nil
 }
@@ -70,43 +157,15 @@ type FreeParams struct {
 func (a *FreeParams) Execute(args []string) error {
 	InitPathVariables(ConfigOptions.List)
 
-	// Read Sync ID and Sync URL from syncfile
-	var (
-		syncID           string
-		syncURL          string
-		syncfileContents string
-	)
+	sf := ReadSyncfile(SyncfilePath)
+
+	syncID := sf.SyncID
 
 	if a.Args.SyncID != "" {
 		syncID = a.Args.SyncID
 	}
 
-	syncfile := OpenSyncfile(false)
-	defer syncfile.Close()
-
-	scanner := bufio.NewScanner(syncfile)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		if syncID == "" {
-			if m := SyncIDPattern.FindStringSubmatch(line); len(m) == 2 {
-				syncID = strings.TrimSpace(m[1])
-				continue
-			}
-		}
-
-		if m := SyncURLPattern.FindStringSubmatch(line); len(m) == 2 {
-			syncURL = strings.TrimSpace(m[1])
-			continue
-		}
-
-		syncfileContents += line
-	}
-
-	if err := scanner.Err(); err != nil {
-		Error(ErrSyncfileRead, SyncfilePath, err)
-	}
+	syncURL := sf.SyncURL
 
 	if syncURL == "" {
 		syncURL = strings.TrimSpace(ConfigOptions.FallbackSyncURL)
@@ -114,41 +173,34 @@ func (a *FreeParams) Execute(args []string) error {
 
 	EnsureTrailingSlash(&syncURL)
 
-	// Request deletion from the Sync Service
-	url := syncURL + syncID
-	request, err := http.NewRequest("DELETE", url, nil)
+	var (
+		auth    SyncAuth
+		network NetworkSettings
+	)
 
-	if err != nil {
-		Error(ErrDELETEReqCreate, url, err)
+	if sf.Auth != nil {
+		auth = *sf.Auth
 	}
 
-	resp, err := http.DefaultClient.Do(request)
-
-	if err != nil {
-		Error(ErrDELETEReqComplete, url, err)
+	if sf.Network != nil {
+		network = *sf.Network
 	}
 
-	defer resp.Body.Close()
-
-	statusCode := resp.StatusCode
+	// Request deletion from the Sync Service
+	ConfigureSyncClient(network)
 
-	switch statusCode {
-	case 404:
-		Error(ErrNoSyncID, syncID)
-	case 405:
-		Error(ErrUnsupportedConfig, url)
-	case 200:
-		// Remove the syncID line from the syncfile
-		syncfile = CreateSyncfile()
-		defer syncfile.Close()
+	err := NewSyncBackend(syncURL, auth).Delete(syncID)
 
-		_, err = syncfile.WriteString(syncfileContents + "\n")
+	ExitOnSyncError(err, syncURL)
 
-		if err != nil {
-			Error(ErrSyncfileWrite, SyncfilePath, err)
-		}
-	default:
-		Error(ErrInvalidResponse, url, "status is "+resp.Status)
+	// Only the Sync ID of the tasklist itself is removed from the syncfile.
+	if syncID == sf.SyncID {
+		sf.SyncID = ""
+		sf.SyncURL = ""
+		sf.ETag = ""
+		sf.LastNetworkUpdate = nil
+		sf.Ancestors = nil
+		sf.Write(SyncfilePath)
 	}
 
 	return nil
@@ -193,6 +245,139 @@ func (a *SwitchParams) Execute(args []string) error {
 	return nil
 }
 
+// PullParams holds the command line arguments for the `sync pull` subcommand.
+type PullParams struct {
+	DryRun bool `short:"n" long:"dry-run" description:"Only show the changes that would be made to the local tasklist"`
+}
+
+// Execute uses the provided PullParams and overwrites the local tasklist with
+// the synced one.
+func (a *PullParams) Execute(args []string) error {
+	lm := &TasklistManager{}
+
+	lm.Init()
+	lm.ParseSyncfile()
+
+	if lm.syncID == "" {
+		Error(ErrSyncDisabled, SyncfilePath)
+	}
+
+	lm.InitBackend()
+
+	_, _, err := lm.fetch()
+
+	ExitOnSyncError(err, lm.syncURL)
+
+	MainList.LoadLocal()
+	DoneList.LoadLocal()
+
+	changes := DiffSnapshots(NewSnapshot(MainList, DoneList), lm.remote)
+
+	if a.DryRun {
+		printChanges("Changes to the local tasklist", changes)
+		return nil
+	}
+
+	// SaveLocal backs up the current taskfiles before overwriting them.
+	lm.remote.Apply(MainList, DoneList)
+
+	MainList.MarkModified()
+	DoneList.MarkModified()
+
+	lm.saveLocal()
+	lm.markSynced(lm.payload())
+
+	printChanges("Pulled changes", changes)
+
+	RunCallback()
+
+	return nil
+}
+
+// StatusParams holds the command line arguments for the `sync status` subcommand.
+type StatusParams struct{}
+
+// Execute uses the provided StatusParams and compares the local and the synced
+// tasklist with their last synced state. Exits with a status code describing
+// which side has changed.
+func (a *StatusParams) Execute(args []string) error {
+	lm := &TasklistManager{}
+
+	lm.Init()
+	lm.ParseSyncfile()
+
+	if lm.syncID == "" {
+		Error(ErrSyncDisabled, SyncfilePath)
+	}
+
+	lm.InitBackend()
+
+	fmt.Printf("Sync ID:             %s\n", lm.syncID)
+	fmt.Printf("Sync URL:            %s\n", lm.syncURL)
+
+	if lm.lastNetworkUpdate.IsZero() {
+		fmt.Println("Last network update: never")
+	} else {
+		fmt.Printf("Last network update: %s\n", lm.lastNetworkUpdate.Format(LastNetworkUpdateFormat))
+	}
+
+	fmt.Printf("Taskfile:            %s\n", describeMTime(MainList, lm.lastNetworkUpdate))
+	fmt.Printf("Donefile:            %s\n", describeMTime(DoneList, lm.lastNetworkUpdate))
+
+	_, _, err := lm.fetch()
+
+	ExitOnSyncError(err, lm.syncURL)
+
+	MainList.LoadLocal()
+	DoneList.LoadLocal()
+
+	base := LoadBaseSnapshot()
+	local := NewSnapshot(MainList, DoneList)
+
+	localChanges := DiffSnapshots(base, local)
+	remoteChanges := DiffSnapshots(base, lm.remote)
+
+	printChanges("Local changes", localChanges)
+	printChanges("Remote changes", remoteChanges)
+
+	status := SyncStatus(base, local, lm.remote)
+
+	fmt.Printf("\nStatus: %s\n", map[int]string{
+		StatusInSync:      "in sync",
+		StatusLocalAhead:  "local ahead",
+		StatusRemoteAhead: "remote ahead",
+		StatusDiverged:    "diverged",
+	}[status])
+
+	os.Exit(status)
+
+	return nil
+}
+
+// describeMTime describes whether a taskfile was modified after the last
+// network update.
+func describeMTime(tl *Tasklist, lastNetworkUpdate time.Time) string {
+	if tl.MTimeAfter(lastNetworkUpdate) {
+		return "newer than the last network update"
+	}
+
+	return "not modified since the last network update"
+}
+
+// printChanges prints a list of task changes under a heading.
+func printChanges(heading string, changes []SnapshotChange) {
+	fmt.Printf("\n%s:\n", heading)
+
+	if len(changes) == 0 {
+		fmt.Println("  none")
+		return
+	}
+
+	for _, change := range changes {
+		fmt.Printf("  %-9s %s\n", change.Kind+":", change.Entry.task.text)
+	}
+}
+
 // init gets called when the package is imported; assigns functions to the
 // respective action and adds the subcommand to the global argument parser.
 func init() {
@@ -206,39 +391,82 @@ func enable(syncURL string, syncID string) {
 	if syncID != "" {
 		newSyncID = syncID
 	} else {
-		resp, err := http.Post(syncURL, "application/json", nil)
+		lm := &TasklistManager{}
+		lm.ParseSyncfile()
 
-		if err != nil {
-			Error(ErrRequestSyncID, syncURL, err)
-		}
+		ConfigureSyncClient(lm.network)
 
-		defer resp.Body.Close()
+		var err error
 
-		if resp.StatusCode != 201 {
-			Error(ErrInvalidResponse, syncURL, "status is "+resp.Status)
-		}
+		newSyncID, err = NewSyncBackend(syncURL, lm.auth).Create()
 
-		// Extract the new syncID from the updated location header.
-		location, err := resp.Location()
+		ExitOnSyncError(err, syncURL)
+	}
 
-		if err != nil {
-			Error(ErrInvalidResponse, err)
-		}
+	newSyncID = strings.TrimSpace(newSyncID)
+
+	setSyncID(SyncfilePath, newSyncID, syncURL)
+
+	upload()
 
-		locationString := location.String()
-		newSyncID = locationString[strings.LastIndex(locationString, "/")+1:]
+	fmt.Printf("\"%s\" from \"%s\"", newSyncID, syncURL)
+}
+
+// enableWorkspace syncs a directory as a workspace. The syncfile is only
+// written once the first sync succeeded, so a failed attempt leaves the
+// directory unsynced.
+func enableWorkspace(dir string, syncURL string, syncID string, configure func(sf *Syncfile)) {
+	sf := ReadSyncfile(WorkspaceSyncfilePath(dir))
+
+	if configure != nil {
+		configure(sf)
+	}
+
+	newSyncID := syncID
+
+	if newSyncID == "" {
+		lm := &TasklistManager{}
+		lm.applySyncfile(sf)
+
+		ConfigureSyncClient(lm.network)
+
+		var err error
+
+		newSyncID, err = NewSyncBackend(syncURL, lm.auth).Create()
+
+		ExitOnSyncError(err, syncURL)
 	}
 
 	newSyncID = strings.TrimSpace(newSyncID)
 
-	ReplaceOrAppendSyncfileLine(SyncIDPattern, "syncID: "+newSyncID+"\n")
-	ReplaceOrAppendSyncfileLine(SyncURLPattern, "syncURL: "+syncURL+"\n")
+	pairSyncfile(sf, newSyncID, syncURL)
 
-	upload()
+	// Upload local lists and download the ones created on other devices.
+	ExitOnSyncError(newWorkspace(dir, sf).Sync(), syncURL)
 
 	fmt.Printf("\"%s\" from \"%s\"", newSyncID, syncURL)
 }
 
+// setSyncID pairs a syncfile with a Sync ID.
+func setSyncID(syncfilePath string, syncID string, syncURL string) {
+	UpdateSyncfile(syncfilePath, func(sf *Syncfile) {
+		pairSyncfile(sf, syncID, syncURL)
+	})
+}
+
+// pairSyncfile sets the Sync ID of a syncfile. The state of the previous
+// Sync ID is discarded, as it does not apply to the new one.
+func pairSyncfile(sf *Syncfile, syncID string, syncURL string) {
+	if sf.SyncID != syncID || sf.SyncURL != syncURL {
+		sf.ETag = ""
+		sf.LastNetworkUpdate = nil
+		sf.Ancestors = nil
+	}
+
+	sf.SyncID = syncID
+	sf.SyncURL = syncURL
+}
+
 func upload() {
 	// Simulate an outdated taskfile scenario using a TasklistMangager and
 	// upload.
@@ -249,6 +477,7 @@ func upload() {
 	lm.Init()
 
 	lm.ParseSyncfile()
+	lm.InitBackend()
 
 	MainList.LoadLocal()
 	DoneList.LoadLocal()
@@ -3,6 +3,7 @@ package main
 import (
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 )
 
@@ -151,3 +152,40 @@ func TestSyncing(t *testing.T) {
 		AssertEqual(t, lm.syncURL, switchingTs.URL+"/", "Simulated TasklistManager's syncURL field has not changed")
 	}
 }
+
+func TestPull(t *testing.T) {
+	vs := &versionedStorage{}
+	vs.setContents(mergeTaskA+mergeTaskB, mergeTaskC)
+	ts := httptest.NewServer(vs)
+	defer ts.Close()
+
+	InitTestingSyncEnv(t, ts.URL)
+
+	taskfile := CreateTaskfile(TaskfilePath)
+	taskfile.WriteString("corrupted\n")
+	taskfile.Close()
+
+	// A dry run does not touch the local taskfile.
+	params := PullParams{DryRun: true}
+	params.Execute([]string{})
+
+	contents, _ := os.ReadFile(TaskfilePath)
+	AssertEqual(t, string(contents), "corrupted\n", "Dry run modified the local taskfile")
+
+	params.DryRun = false
+	params.Execute([]string{})
+
+	contents, _ = os.ReadFile(TaskfilePath)
+	AssertEqual(t, string(contents), mergeTaskA+mergeTaskB, "Taskfile does not match the synced tasklist")
+
+	doneContents, _ := os.ReadFile(DonefilePath)
+	AssertEqual(t, string(doneContents), mergeTaskC, "Donefile does not match the synced tasklist")
+
+	backup, _ := os.ReadFile(GetMetafilePath(".bak", TaskfilePath))
+	AssertEqual(t, string(backup), "corrupted\n", "Local taskfile was not backed up")
+
+	lm := &TasklistManager{}
+	lm.ParseSyncfile()
+
+	AssertNotEqual(t, lm.lastNetworkUpdate.IsZero(), true, "lastNetworkUpdate was not updated")
+}
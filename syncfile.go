@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SyncfileVersion is the version of the syncfile format written by tx.
+// Syncfiles which are not JSON objects use the legacy "key: value" format and
+// are migrated when they are first read.
+const SyncfileVersion = 1
+
+// Syncfile holds the sync settings and state of a tasklist or a workspace.
+// Secrets are never stored, only references to where they are read from.
+type Syncfile struct {
+	Version           int                   `json:"version"`
+	SyncID            string                `json:"syncID,omitempty"`
+	SyncURL           string                `json:"syncURL,omitempty"`
+	Backend           string                `json:"backend,omitempty"`  // The scheme of the Sync URL.
+	DeviceID          string                `json:"deviceID,omitempty"` // Identifies this device on the Sync service.
+	ETag              string                `json:"etag,omitempty"`     // The entity tag of the last synced tasklist.
+	LastNetworkUpdate *time.Time            `json:"lastNetworkUpdate,omitempty"`
+	Encryption        *SyncfileEncryption   `json:"encryption,omitempty"`
+	Auth              *SyncAuth             `json:"auth,omitempty"`
+	Network           *NetworkSettings      `json:"network,omitempty"`
+	Ancestors         map[string]SyncedList `json:"ancestors,omitempty"` // The last synced state of every list, by name.
+}
+
+// SyncfileEncryption holds the encryption settings of a syncfile.
+type SyncfileEncryption struct {
+	Scheme    string `json:"scheme"`
+	KeySource string `json:"keySource,omitempty"`
+	Uploaded  bool   `json:"uploaded,omitempty"` // An encrypted tasklist has been uploaded.
+}
+
+// markEncryptedUpload records that the tasklist has been uploaded encrypted,
+// if encryption is enabled.
+func (sf *Syncfile) markEncryptedUpload() {
+	if sf.Encryption != nil {
+		sf.Encryption.Uploaded = true
+	}
+}
+
+// ListName returns the name of the tasklist in a taskfile, used as its key
+// in the ancestors of a syncfile.
+func ListName(taskfilePath string) string {
+	return filepath.Base(taskfilePath)
+}
+
+// ReadSyncfile reads the syncfile at the provided path. A missing syncfile
+// results in an empty one. Legacy syncfiles are migrated and written back in
+// the current format.
+func ReadSyncfile(syncfilePath string) *Syncfile {
+	contents, err := os.ReadFile(syncfilePath)
+
+	if os.IsNotExist(err) {
+		return &Syncfile{Version: SyncfileVersion}
+	}
+
+	if err != nil {
+		Error(ErrSyncfileRead, syncfilePath, err)
+	}
+
+	if trimmed := strings.TrimSpace(string(contents)); trimmed == "" || trimmed[0] != '{' {
+		sf := migrateLegacySyncfile(syncfilePath, trimmed)
+		sf.Write(syncfilePath)
+
+		// The ancestors used to be stored in a separate basefile.
+		if err := os.Remove(legacyBasefilePath(syncfilePath)); err != nil && !os.IsNotExist(err) {
+			Warn("Could not delete legacy basefile \"%s\": %v", legacyBasefilePath(syncfilePath), err)
+		}
+
+		return sf
+	}
+
+	var sf Syncfile
+
+	if err := json.Unmarshal(contents, &sf); err != nil {
+		Error(ErrSyncfileParse, syncfilePath, err)
+	}
+
+	if sf.Version > SyncfileVersion {
+		Error(ErrSyncfileVersion, syncfilePath, sf.Version, SyncfileVersion)
+	}
+
+	return &sf
+}
+
+// UpdateSyncfile reads the syncfile at the provided path, applies the update
+// and writes it back.
+func UpdateSyncfile(syncfilePath string, update func(sf *Syncfile)) {
+	sf := ReadSyncfile(syncfilePath)
+
+	update(sf)
+
+	sf.Write(syncfilePath)
+}
+
+// Write stores the syncfile at the provided path in the current format.
+func (sf *Syncfile) Write(syncfilePath string) {
+	sf.Version = SyncfileVersion
+	sf.Backend = ""
+
+	if parsedURL, err := url.Parse(sf.SyncURL); err == nil && sf.SyncURL != "" {
+		sf.Backend = strings.ToLower(parsedURL.Scheme)
+	}
+
+	if sf.DeviceID == "" && sf.SyncID != "" {
+		buf := make([]byte, 8)
+		rand.Read(buf)
+
+		sf.DeviceID = fmt.Sprintf("%x", buf)
+	}
+
+	jsonData, err := json.MarshalIndent(sf, "", "  ")
+
+	if err != nil {
+		Error(ErrSerializeJSON, err)
+	}
+
+	syncfile := createFile(syncfilePath, ErrSyncfileOpen)
+	defer syncfile.Close()
+
+	if _, err := syncfile.Write(append(jsonData, '\n')); err != nil {
+		Error(ErrSyncfileWrite, syncfilePath, err)
+	}
+}
+
+// Disable removes the Sync ID along with every setting and state tied to the
+// Sync service. Network settings and the device ID are kept.
+func (sf *Syncfile) Disable() {
+	*sf = Syncfile{
+		Version:  sf.Version,
+		DeviceID: sf.DeviceID,
+		Network:  sf.Network,
+	}
+}
+
+// legacyBasefilePath returns the path of the basefile used by legacy
+// syncfiles: "./.{taskfile}.base" next to "./.{taskfile}.sync".
+func legacyBasefilePath(syncfilePath string) string {
+	return strings.TrimSuffix(syncfilePath, ".sync") + ".base"
+}
+
+// migrateLegacySyncfile converts the "key: value" lines of a legacy syncfile
+// and its basefile into the current format.
+func migrateLegacySyncfile(syncfilePath string, contents string) *Syncfile {
+	sf := &Syncfile{Version: SyncfileVersion}
+
+	var (
+		auth    SyncAuth
+		network NetworkSettings
+	)
+
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if auth.ParseLine(line) || network.ParseLine(line) {
+			continue
+		}
+
+		if m := SyncIDPattern.FindStringSubmatch(line); len(m) == 2 {
+			sf.SyncID = strings.TrimSpace(m[1])
+			continue
+		}
+
+		if m := SyncURLPattern.FindStringSubmatch(line); len(m) == 2 {
+			sf.SyncURL = strings.TrimSpace(m[1])
+			continue
+		}
+
+		if m := EncryptionPattern.FindStringSubmatch(line); len(m) == 2 {
+			if sf.Encryption == nil {
+				sf.Encryption = &SyncfileEncryption{}
+			}
+
+			sf.Encryption.Scheme = strings.ToLower(strings.TrimSpace(m[1]))
+			continue
+		}
+
+		if m := KeySourcePattern.FindStringSubmatch(line); len(m) == 2 {
+			if sf.Encryption == nil {
+				sf.Encryption = &SyncfileEncryption{}
+			}
+
+			sf.Encryption.KeySource = strings.TrimSpace(m[1])
+			continue
+		}
+
+		if m := LastNetworkUpdatePattern.FindStringSubmatch(line); len(m) == 2 {
+			lastNetworkUpdate, err := time.ParseInLocation(LastNetworkUpdateFormat, m[1], time.UTC)
+
+			if err != nil {
+				Warn("Invalid lastNetworkUpdate date in syncfile \"%s\": %s", syncfilePath, m[1])
+				continue
+			}
+
+			sf.LastNetworkUpdate = &lastNetworkUpdate
+		}
+	}
+
+	// A keySource without an encryption scheme has no effect.
+	if sf.Encryption != nil && sf.Encryption.Scheme == "" {
+		sf.Encryption = nil
+	}
+
+	if auth.Type != "" || len(auth.Headers) > 0 {
+		sf.Auth = &auth
+	}
+
+	if network != (NetworkSettings{}) {
+		sf.Network = &network
+	}
+
+	sf.Ancestors = readLegacyBasefile(syncfilePath)
+
+	return sf
+}
+
+// readLegacyBasefile reads the ancestors stored in the basefile of a legacy
+// syncfile. Basefiles of workspaces hold every list, basefiles of tasklists a
+// single one.
+func readLegacyBasefile(syncfilePath string) map[string]SyncedList {
+	basefilePath := legacyBasefilePath(syncfilePath)
+	jsonData, err := os.ReadFile(basefilePath)
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		Error(ErrBasefileRead, basefilePath, err)
+	}
+
+	var data struct {
+		SyncedList
+		Lists map[string]SyncedList `json:"lists"`
+	}
+
+	if err := json.Unmarshal(jsonData, &data); err != nil {
+		Warn("Could not parse basefile \"%s\": %v", basefilePath, err)
+		return nil
+	}
+
+	if data.Lists != nil {
+		return data.Lists
+	}
+
+	// "./.{taskfile}.sync" belongs to the list "{taskfile}".
+	name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(syncfilePath), "."), ".sync")
+
+	return map[string]SyncedList{name: data.SyncedList}
+}
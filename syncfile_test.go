@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyncfile(t *testing.T) {
+	t.Run("migrate", func(t *testing.T) {
+		InitTestingPathVariables(t)
+
+		legacy := "syncID: legacy-id\n" +
+			"syncURL: https://sync.example.com/\n" +
+			"lastNetworkUpdate: 2024/05/06/07/08/09\n" +
+			"encryption: AES-256-GCM\n" +
+			"keySource: env:TX_TESTING_KEY\n" +
+			"authType: bearer\n" +
+			"header: X-Api-Key: env:API_KEY\n" +
+			"timeout: 5s\n" +
+			"retries: 0\n"
+
+		os.WriteFile(SyncfilePath, []byte(legacy), 0644)
+		os.WriteFile(legacyBasefilePath(SyncfilePath), []byte(`{"contents":"A | creation: 2024/05/06/07/08\n","doneContents":""}`), 0644)
+
+		sf := ReadSyncfile(SyncfilePath)
+
+		AssertEqual(t, sf.Version, SyncfileVersion, "Migrated syncfile has the wrong version")
+		AssertEqual(t, sf.SyncID, "legacy-id", "Sync ID was not migrated")
+		AssertEqual(t, sf.SyncURL, "https://sync.example.com/", "Sync URL was not migrated")
+		AssertEqual(t, *sf.LastNetworkUpdate, time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC), "Last network update was not migrated")
+		AssertEqual(t, *sf.Encryption, SyncfileEncryption{Scheme: EncryptionAES256GCM, KeySource: "env:TX_TESTING_KEY"}, "Encryption was not migrated")
+		AssertEqual(t, sf.Auth.Type, AuthBearer, "Authentication type was not migrated")
+		AssertEqual(t, sf.Auth.Headers[0], "X-Api-Key: env:API_KEY", "Header was not migrated")
+		AssertEqual(t, sf.Network.Timeout, 5*time.Second, "Timeout was not migrated")
+		AssertEqual(t, *sf.Network.Retries, 0, "Retries were not migrated")
+		AssertEqual(t, sf.Ancestors["tasks"].Contents, "A | creation: 2024/05/06/07/08\n", "Basefile was not migrated")
+
+		_, err := os.Stat(legacyBasefilePath(SyncfilePath))
+
+		AssertEqual(t, os.IsNotExist(err), true, "Legacy basefile was not deleted")
+
+		// The migrated syncfile is written back and read as is.
+		contents, _ := os.ReadFile(SyncfilePath)
+
+		AssertEqual(t, strings.HasPrefix(string(contents), "{"), true, "Migrated syncfile was not written back")
+
+		migrated := ReadSyncfile(SyncfilePath)
+
+		AssertEqual(t, migrated.Backend, "https", "Backend was not recorded")
+		AssertNotEqual(t, migrated.DeviceID, "", "Device ID was not generated")
+		AssertEqual(t, migrated.Network.Timeout, 5*time.Second, "Timeout did not survive a round trip")
+		AssertEqual(t, migrated.Ancestors["tasks"], sf.Ancestors["tasks"], "Ancestor did not survive a round trip")
+	})
+
+	t.Run("migrate_workspace", func(t *testing.T) {
+		dir := t.TempDir()
+
+		os.WriteFile(WorkspaceSyncfilePath(dir), []byte("syncID: workspace-id\nsyncURL: http://localhost/\n"), 0644)
+		os.WriteFile(filepath.Join(dir, ".workspace.base"), []byte(`{"lists":{"work":{"contents":"A\n","doneContents":""}}}`), 0644)
+
+		sf := ReadSyncfile(WorkspaceSyncfilePath(dir))
+
+		AssertEqual(t, sf.SyncID, "workspace-id", "Sync ID was not migrated")
+		AssertEqual(t, len(sf.Ancestors), 1, "Workspace basefile was not migrated")
+		AssertEqual(t, sf.Ancestors["work"].Contents, "A\n", "Workspace list was not migrated")
+	})
+
+	t.Run("disable", func(t *testing.T) {
+		InitTestingPathVariables(t)
+
+		retries := 1
+
+		(&Syncfile{
+			SyncID:    "id",
+			SyncURL:   "http://localhost/",
+			Network:   &NetworkSettings{Retries: &retries},
+			Ancestors: map[string]SyncedList{"tasks": {Contents: "A\n"}},
+		}).Write(SyncfilePath)
+
+		UpdateSyncfile(SyncfilePath, (*Syncfile).Disable)
+
+		sf := ReadSyncfile(SyncfilePath)
+
+		AssertEqual(t, sf.SyncID, "", "Sync ID was not removed")
+		AssertEqual(t, len(sf.Ancestors), 0, "Ancestors were not removed")
+		AssertEqual(t, *sf.Network.Retries, 1, "Network settings were removed")
+		AssertNotEqual(t, sf.DeviceID, "", "Device ID was removed")
+	})
+
+	t.Run("newer_version", func(t *testing.T) {
+		AssertExitError(t, "TestSyncfile/newer_version", ErrSyncfileVersion, func() {
+			InitTestingPathVariables(t)
+
+			os.WriteFile(SyncfilePath, []byte(`{"version": 99}`), 0644)
+
+			ReadSyncfile(SyncfilePath)
+		})
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		AssertExitError(t, "TestSyncfile/invalid", ErrSyncfileParse, func() {
+			InitTestingPathVariables(t)
+
+			os.WriteFile(SyncfilePath, []byte(`{"version": "one"}`), 0644)
+
+			ReadSyncfile(SyncfilePath)
+		})
+	})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Tag prefixes recognized in task text.
+const (
+	TagPrefix     = "+"
+	ContextPrefix = "@"
+)
+
+// ExtractTags returns the +tags and @contexts in a task's text, in order of
+// appearance and without duplicates.
+func ExtractTags(text string) (tags []string) {
+	seen := make(map[string]bool)
+
+	for _, m := range TagPattern.FindAllStringSubmatch(text, -1) {
+		tag := strings.ToLower(m[1])
+
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	return
+}
+
+// IsTagSelector reports whether a selector refers to every task carrying a
+// tag or context instead of indexes.
+func IsTagSelector(selector string) bool {
+	tags := ExtractTags(selector)
+
+	return len(tags) == 1 && tags[0] == strings.ToLower(strings.TrimSpace(selector))
+}
+
+// HasTag reports whether the task carries the provided tag or context.
+func (t Task) HasTag(tag string) bool {
+	tag = strings.ToLower(tag)
+
+	for _, own := range t.tags {
+		if own == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ListFilters holds the filtering options shared by every listing mode.
+type ListFilters struct {
+	Tags       []string `long:"tag" description:"Only list tasks with this +tag. Can be repeated." value-name:"TAG"`
+	Contexts   []string `long:"context" description:"Only list tasks with this @context. Can be repeated." value-name:"CONTEXT"`
+	Attributes []string `long:"attr" description:"Only list tasks with this attribute, or with this attribute set to VALUE. Can be repeated." value-name:"KEY[=VALUE]"`
+}
+
+// TaskFilters converts the options into filters for Tasklist.Show. Tasks have
+// to carry every requested tag, context and attribute.
+func (f ListFilters) TaskFilters() (filters []TaskFilter) {
+	for _, tag := range f.Tags {
+		filters = append(filters, tagFilter(TagPrefix+strings.TrimPrefix(tag, TagPrefix)))
+	}
+
+	for _, context := range f.Contexts {
+		filters = append(filters, tagFilter(ContextPrefix+strings.TrimPrefix(context, ContextPrefix)))
+	}
+
+	for _, attribute := range f.Attributes {
+		filters = append(filters, attrFilter(attribute))
+	}
+
+	return
+}
+
+func tagFilter(tag string) TaskFilter {
+	return func(task Task) bool { return task.HasTag(tag) }
+}
+
+// TagsParams holds the command line arguments for the `tags` command.
+type TagsParams struct{}
+
+// Execute lists every tag and context along with the number of active and
+// finished tasks carrying it.
+func (a *TagsParams) Execute(args []string) error {
+	ListManager.EnsureInitialized(MainList)
+	ListManager.EnsureInitialized(DoneList)
+
+	active := countTags(MainList)
+	finished := countTags(DoneList)
+
+	var tags []string
+
+	for tag := range active {
+		tags = append(tags, tag)
+	}
+
+	for tag := range finished {
+		if _, ok := active[tag]; !ok {
+			tags = append(tags, tag)
+		}
+	}
+
+	sort.Strings(tags)
+
+	for _, tag := range tags {
+		fmt.Printf("%-20s %d active, %d finished\n", tag, active[tag], finished[tag])
+	}
+
+	return nil
+}
+
+// countTags counts the tasks carrying each tag in a tasklist.
+func countTags(tl *Tasklist) map[string]int {
+	counts := make(map[string]int)
+
+	for _, task := range tl.tasks {
+		for _, tag := range task.tags {
+			counts[tag]++
+		}
+	}
+
+	return counts
+}
+
+// init gets called when the package is imported; adds the command to the
+// global argument parser.
+func init() {
+	GlobalParser.AddCommand("tags", "List tags and contexts", "", &TagsParams{})
+}
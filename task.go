@@ -3,6 +3,7 @@ package main
 import (
 	"crypto/sha1"
 	"fmt"
+	"math"
 	"strings"
 	"time"
 )
@@ -13,7 +14,18 @@ type Task struct {
 	text         string
 	creationDate time.Time
 	finishedDate time.Time
-	hash         string
+	hash         string      // The ID of the task, which never changes.
+	priority     string      // From "A" (highest) to "Z", empty if unset.
+	due          time.Time   // The deadline of the task, zero if unset.
+	wait         time.Time   // The date the task is snoozed until, zero if unset.
+	tags         []string    // The +tags and @contexts in the text, see ExtractTags.
+	parent       string      // The hash of the parent task, empty for top-level tasks.
+	recurrence   string      // The recurrence rule (see ParseRecurrence), empty if unset.
+	note         string      // Free-form notes, which may span multiple lines.
+	blockedBy    []string    // The hashes of the tasks which have to be finished first.
+	tracked      TimeLog     // The time worked on the task per day.
+	started      time.Time   // The start of the running timer, zero if not running.
+	attributes   []Attribute // Metadata fields not interpreted by tx, see metadataKeys.
 }
 
 // Validate checks if a task contains valid data and corrects common errors.
@@ -35,7 +47,52 @@ func (t *Task) Serialize() (data []byte) {
 	creationString := t.creationDate.Format(FullDateFormat)
 	finishedString := t.finishedDate.Format(FullDateFormat)
 
-	line := fmt.Sprintf("%s | id:%s, creation:%s, finished:%s\n", escapedText, t.hash, creationString, finishedString)
+	line := fmt.Sprintf("%s | id:%s, creation:%s, finished:%s", escapedText, t.hash, creationString, finishedString)
+
+	// Optional fields are only written when set, so tasklists not using them
+	// stay unchanged.
+	if t.priority != "" {
+		line += ", priority:" + t.priority
+	}
+
+	if !t.due.IsZero() {
+		line += ", due:" + t.due.Format(FullDateFormat)
+	}
+
+	if !t.wait.IsZero() {
+		line += ", wait:" + t.wait.Format(FullDateFormat)
+	}
+
+	if t.parent != "" {
+		line += ", parent:" + t.parent
+	}
+
+	if t.recurrence != "" {
+		line += ", every:" + t.recurrence
+	}
+
+	if len(t.blockedBy) != 0 {
+		line += ", blockedBy:" + strings.Join(t.blockedBy, BlockerSeparator)
+	}
+
+	if len(t.tracked) != 0 {
+		line += ", tracked:" + t.tracked.String()
+	}
+
+	if !t.started.IsZero() {
+		line += ", started:" + t.started.Format(FullDateFormat)
+	}
+
+	if t.note != "" {
+		line += ", note:" + EscapeNote(t.note)
+	}
+
+	// Unknown fields are written back verbatim.
+	if len(t.attributes) != 0 {
+		line += ", " + serializeAttributes(t.attributes)
+	}
+
+	line += "\n"
 
 	data = []byte(line)
 	return
@@ -44,7 +101,7 @@ func (t *Task) Serialize() (data []byte) {
 // ParseTask takes a line from a taskfile and creates a Task object from it.
 func ParseTask(line string) (newTask Task, err error) {
 	line = strings.TrimSpace(line)
-	newTask.creationDate = time.Now()
+	newTask.creationDate = Now()
 	newTask.finishedDate = time.Unix(0, 0)
 
 	if line == "" {
@@ -57,6 +114,10 @@ func ParseTask(line string) (newTask Task, err error) {
 	if !SeparatorPattern.MatchString(line) {
 		newTask = NewTask(strings.ReplaceAll(line, `\|`, `|`))
 
+		// Tasks added to the taskfile by hand or by other tools get the same
+		// ID on every device.
+		newTask.hash = hexHash(newTask.text)
+
 		err = fmt.Errorf("writeNewMeta")
 
 		return
@@ -69,41 +130,34 @@ func ParseTask(line string) (newTask Task, err error) {
 	separatorLocation := SeparatorPattern.FindStringIndex(line)[0] + 1
 
 	newTask.text = strings.TrimSpace(strings.ReplaceAll(line[:separatorLocation], `\|`, `|`))
+	newTask.tags = ExtractTags(newTask.text)
 	metadata := strings.TrimSpace(line[separatorLocation+1:])
 
-	parsedCrDate := CreationDatePattern.FindStringSubmatch(metadata)
+	var hasCreation, hasFinished bool
 
-	if len(parsedCrDate) != 0 {
-		creationDate, err := time.Parse(FullDateFormat, parsedCrDate[1])
-
-		if err == nil {
-			newTask.creationDate = creationDate
-		} else {
-			Warn("Could not parse creation date: \"%s\". Using current time.", parsedCrDate[1])
+	for _, field := range ParseMetadata(metadata) {
+		switch strings.ToLower(field.Key) {
+		case "creation":
+			hasCreation = true
+		case "finished":
+			hasFinished = true
 		}
-	} else {
-		err = fmt.Errorf("writeNewMeta")
-	}
-
-	parsedFiDate := FinishedDatePattern.FindStringSubmatch(metadata)
 
-	if len(parsedFiDate) != 0 {
-		finishedDate, err := time.Parse(FullDateFormat, parsedFiDate[1])
+		if !IsReservedKey(field.Key) {
+			newTask.attributes = append(newTask.attributes, field)
+			continue
+		}
 
-		if err == nil {
-			newTask.finishedDate = finishedDate
-		} else {
-			Warn("Could not parse finished date: \"%s\". Using Unix epoch.")
+		if parseErr := newTask.parseField(strings.ToLower(field.Key), field.Value); parseErr != nil {
+			Warn("Could not parse %s: \"%s\". Ignoring it.", field.Key, field.Value)
 		}
-	} else {
-		err = fmt.Errorf("writeNewMeta")
 	}
 
-	parsedHash := HashPattern.FindStringSubmatch(metadata)
+	if !hasCreation || !hasFinished {
+		err = fmt.Errorf("writeNewMeta")
+	}
 
-	if len(parsedHash) != 0 {
-		newTask.hash = parsedHash[1]
-	} else {
+	if newTask.hash == "" {
 		newTask.hash = hexHash(newTask.text)
 		err = fmt.Errorf("writeNewMeta")
 	}
@@ -111,16 +165,142 @@ func ParseTask(line string) (newTask Task, err error) {
 	return
 }
 
+// parseField interprets a metadata field with a reserved key (see
+// metadataKeys). The task is left unchanged if the value is invalid.
+func (t *Task) parseField(key string, value string) error {
+	switch key {
+	case "id":
+		if !HashValuePattern.MatchString(value) {
+			return fmt.Errorf("invalid hash")
+		}
+
+		t.hash = value
+	case "creation", "finished", "due", "wait", "started":
+		date, err := time.Parse(FullDateFormat, value)
+
+		if err != nil {
+			return err
+		}
+
+		switch key {
+		case "creation":
+			t.creationDate = date
+		case "finished":
+			t.finishedDate = date
+		case "due":
+			t.due = date
+		case "wait":
+			t.wait = date
+		case "started":
+			t.started = date
+		}
+	case "priority":
+		priority, err := ParsePriority(value)
+
+		if err != nil || priority == "" {
+			return fmt.Errorf("invalid priority")
+		}
+
+		t.priority = priority
+	case "parent":
+		if !HashValuePattern.MatchString(strings.ToLower(value)) {
+			return fmt.Errorf("invalid hash")
+		}
+
+		t.parent = strings.ToLower(value)
+	case "every":
+		if _, err := ParseRecurrence(value); err != nil {
+			return err
+		}
+
+		t.recurrence = strings.ToLower(value)
+	case "blockedby":
+		blockers := strings.Split(strings.ToLower(value), BlockerSeparator)
+
+		for _, hash := range blockers {
+			if !HashValuePattern.MatchString(hash) {
+				return fmt.Errorf("invalid hash")
+			}
+		}
+
+		t.blockedBy = blockers
+	case "tracked":
+		tracked, err := ParseTimeLog(value)
+
+		if err != nil {
+			return err
+		}
+
+		t.tracked = tracked
+	case "note":
+		note, err := UnescapeNote(value)
+
+		if err != nil {
+			return err
+		}
+
+		t.note = note
+	}
+
+	return nil
+}
+
 // NewTask creates a task and sets default values.
 func NewTask(text string) (newTask Task) {
 	newTask.text = text
-	newTask.creationDate = time.Now()
+	newTask.tags = ExtractTags(text)
+	newTask.creationDate = Now()
 	newTask.finishedDate = time.Unix(0, 0)
-	newTask.hash = hexHash(text)
+	newTask.hash = NewID()
 
 	return
 }
 
+// priorityRank orders tasks by priority, placing tasks without one last.
+func (t Task) priorityRank() int {
+	if t.priority == "" {
+		return 'Z' + 1
+	}
+
+	return int(t.priority[0])
+}
+
+// dueRank orders tasks by due date, placing tasks without one last.
+func (t Task) dueRank() int64 {
+	if t.due.IsZero() {
+		return math.MaxInt64
+	}
+
+	return t.due.Unix()
+}
+
+// IsOverdue reports whether the task's due date has passed.
+func (t Task) IsOverdue(now time.Time) bool {
+	return !t.due.IsZero() && t.due.Before(now)
+}
+
+// IsDueWithin reports whether the task is due within the provided duration,
+// including overdue tasks.
+func (t Task) IsDueWithin(now time.Time, d time.Duration) bool {
+	return !t.due.IsZero() && !t.due.After(now.Add(d))
+}
+
+// ParsePriority validates a priority level given on the command line. The
+// levels "-" and "none" remove the priority.
+func ParsePriority(level string) (string, error) {
+	level = strings.TrimSpace(level)
+
+	if level == "-" || strings.EqualFold(level, "none") {
+		return "", nil
+	}
+
+	if len(level) != 1 || !strings.Contains("ABCDEFGHIJKLMNOPQRSTUVWXYZ", strings.ToUpper(level)) {
+		return "", fmt.Errorf("invalid priority \"%s\"", level)
+	}
+
+	return strings.ToUpper(level), nil
+}
+
 func hexHash(s string) (hash string) {
 	return fmt.Sprintf("%x", sha1.Sum([]byte(s)))
 }
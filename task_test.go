@@ -35,6 +35,7 @@ func TestParseTask(t *testing.T) {
 
 		var dt time.Time
 		AssertNotEqual(t, task.creationDate, dt, "Task's creation date is zero-value")
+		AssertEqual(t, task.creationDate.Nanosecond(), 0, "Task's creation date was not set through Now()")
 		AssertTaskFinishedDate(t, task, time.Date(2001, 03, 14, 23, 58, 0, 0, time.UTC))
 	})
 
@@ -56,3 +57,322 @@ func TestParseTask(t *testing.T) {
 		AssertTaskFinishedDate(t, task, time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC))
 	})
 }
+
+func TestTaskPriority(t *testing.T) {
+	t.Run("parse", func(t *testing.T) {
+		task, _ := ParseTask("Urgent | id:7b91fb49a85ea06bb0276e70984d602e62e95ea5, creation:2003/04/15/22/18, finished:1970/01/01/00/00, priority:b")
+
+		AssertEqual(t, task.priority, "B", "Task's priority does not equal \"B\"")
+		AssertEqual(t, string(task.Serialize()), "Urgent | id:7b91fb49a85ea06bb0276e70984d602e62e95ea5, creation:2003/04/15/22/18, finished:1970/01/01/00/00, priority:B\n", "Priority was not serialized")
+	})
+
+	t.Run("unset", func(t *testing.T) {
+		line := "Plain | id:7b91fb49a85ea06bb0276e70984d602e62e95ea5, creation:2003/04/15/22/18, finished:1970/01/01/00/00\n"
+		task, _ := ParseTask(line)
+
+		AssertEqual(t, task.priority, "", "Task without priority has one")
+		AssertEqual(t, string(task.Serialize()), line, "Taskline without priority was changed")
+	})
+
+	t.Run("levels", func(t *testing.T) {
+		for level, want := range map[string]string{"a": "A", "Z": "Z", "-": "", "none": ""} {
+			priority, err := ParsePriority(level)
+
+			AssertEqual(t, err, nil, "Valid priority was rejected: "+level)
+			AssertEqual(t, priority, want, "Priority does not match")
+		}
+
+		for _, level := range []string{"", "AA", "1", "!"} {
+			_, err := ParsePriority(level)
+
+			AssertNotEqual(t, err, nil, "Invalid priority was accepted: "+level)
+		}
+	})
+}
+
+func TestTaskDue(t *testing.T) {
+	t.Run("parse", func(t *testing.T) {
+		line := "Report | id:7b91fb49a85ea06bb0276e70984d602e62e95ea5, creation:2003/04/15/22/18, finished:1970/01/01/00/00, due:2003/04/20/17/00\n"
+		task, _ := ParseTask(line)
+
+		AssertEqual(t, task.due, time.Date(2003, 4, 20, 17, 0, 0, 0, time.UTC), "Task's due date does not match")
+		AssertEqual(t, string(task.Serialize()), line, "Due date was not serialized")
+	})
+
+	t.Run("overdue", func(t *testing.T) {
+		now := time.Date(2003, 4, 21, 12, 0, 0, 0, time.UTC)
+		task := Task{due: time.Date(2003, 4, 20, 17, 0, 0, 0, time.UTC)}
+
+		AssertEqual(t, task.IsOverdue(now), true, "Task is not overdue")
+		AssertEqual(t, Task{}.IsOverdue(now), false, "Task without due date is overdue")
+		AssertEqual(t, DueIn(task.due, now), "19h overdue", "Overdue description does not match")
+		AssertEqual(t, DueIn(now.AddDate(0, 0, 3), now), "in 3d", "Due description does not match")
+	})
+
+	t.Run("due_within", func(t *testing.T) {
+		now := time.Date(2003, 4, 21, 12, 0, 0, 0, time.UTC)
+		week := 7 * 24 * time.Hour
+
+		AssertEqual(t, Task{due: now.AddDate(0, 0, 3)}.IsDueWithin(now, week), true, "Task due in 3 days is not due within a week")
+		AssertEqual(t, Task{due: now.AddDate(0, 0, 10)}.IsDueWithin(now, week), false, "Task due in 10 days is due within a week")
+	})
+
+	t.Run("date_args", func(t *testing.T) {
+		today := Now().Truncate(24 * time.Hour)
+
+		for value, want := range map[string]time.Time{
+			"2024/05/06/07/08": time.Date(2024, 5, 6, 7, 8, 0, 0, time.UTC),
+			"2024/05/06":       time.Date(2024, 5, 6, 23, 59, 0, 0, time.UTC),
+			"2024-05-06":       time.Date(2024, 5, 6, 23, 59, 0, 0, time.UTC),
+			"today":            today.Add(24*time.Hour - time.Minute),
+			"+2d":              today.AddDate(0, 0, 2).Add(24*time.Hour - time.Minute),
+		} {
+			date, err := ParseDateArg(value, true)
+
+			AssertEqual(t, err, nil, "Valid date was rejected: "+value)
+			AssertEqual(t, date, want, "Date does not match: "+value)
+		}
+
+		_, err := ParseDateArg("someday", true)
+
+		AssertNotEqual(t, err, nil, "Invalid date was accepted")
+	})
+}
+
+func TestTaskTags(t *testing.T) {
+	task := NewTask("Ship +Release to @work, then +release notes and an@email.com")
+
+	AssertEqual(t, len(task.tags), 2, "Tags were not extracted")
+	AssertEqual(t, task.tags[0], "+release", "Tag was not lowercased")
+	AssertEqual(t, task.tags[1], "@work", "Context was not extracted")
+	AssertEqual(t, task.HasTag("+RELEASE"), true, "Tag was not matched case-insensitively")
+	AssertEqual(t, task.HasTag("@home"), false, "Missing context was matched")
+
+	AssertEqual(t, IsTagSelector("+release"), true, "Tag selector was not recognized")
+	AssertEqual(t, IsTagSelector("1-3"), false, "Index selector was recognized as a tag")
+	AssertEqual(t, IsTagSelector("+release @work"), false, "Multiple tags were recognized as a selector")
+}
+
+func TestTaskRecurrence(t *testing.T) {
+	t.Run("parse", func(t *testing.T) {
+		for rule, want := range map[string]Recurrence{
+			"1w":       {N: 1, Unit: "w"},
+			"+3d":      {N: 3, Unit: "d", Fixed: true},
+			"monthly":  {N: 1, Unit: "m"},
+			"+yearly":  {N: 1, Unit: "y", Fixed: true},
+			"Weekday":  {N: 1, Unit: RecurWeekday},
+			"+weekday": {N: 1, Unit: RecurWeekday, Fixed: true},
+		} {
+			r, err := ParseRecurrence(rule)
+
+			AssertEqual(t, err, nil, "Valid recurrence was rejected: "+rule)
+			AssertEqual(t, r, want, "Recurrence does not match: "+rule)
+		}
+
+		for _, rule := range []string{"", "0d", "1x", "w", "fortnightly", "++1w"} {
+			_, err := ParseRecurrence(rule)
+
+			AssertNotEqual(t, err, nil, "Invalid recurrence was accepted: "+rule)
+		}
+	})
+
+	t.Run("next", func(t *testing.T) {
+		due := time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC)
+		finished := time.Date(2024, 3, 5, 18, 30, 0, 0, time.UTC) // A Tuesday.
+
+		for rule, want := range map[string]time.Time{
+			"1w":       time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC),
+			"+1w":      time.Date(2024, 3, 6, 9, 0, 0, 0, time.UTC),
+			"monthly":  time.Date(2024, 4, 5, 9, 0, 0, 0, time.UTC),
+			"+monthly": time.Date(2024, 3, 31, 9, 0, 0, 0, time.UTC),
+			"+2m":      time.Date(2024, 3, 31, 9, 0, 0, 0, time.UTC),
+			"+1y":      time.Date(2025, 1, 31, 9, 0, 0, 0, time.UTC),
+		} {
+			r, _ := ParseRecurrence(rule)
+
+			AssertEqual(t, r.Next(due, finished), want, "Next due date does not match: "+rule)
+		}
+
+		friday := time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC)
+		r, _ := ParseRecurrence("weekday")
+
+		AssertEqual(t, r.Next(time.Time{}, friday), time.Date(2024, 3, 11, 23, 59, 0, 0, time.UTC), "Weekday recurrence does not skip the weekend")
+	})
+
+	t.Run("month_end", func(t *testing.T) {
+		AssertEqual(t, addMonths(time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC), 1), time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), "Day was not moved to the end of the month")
+	})
+
+	t.Run("metadata", func(t *testing.T) {
+		task, _ := ParseTask("A | creation:2024/05/06/07/08, finished:1970/01/01/00/00, every:+1w")
+
+		AssertEqual(t, task.recurrence, "+1w", "Recurrence was not parsed")
+		AssertEqual(t, string(task.Serialize()), "A | id:"+task.hash+", creation:2024/05/06/07/08, finished:1970/01/01/00/00, every:+1w\n", "Recurrence was not serialized")
+	})
+}
+
+func TestTaskNote(t *testing.T) {
+	note := "See https://example.com/a|b, due:2020/01/01/00/00\n  then: ping %s"
+
+	task := NewTask("A")
+	task.note = note
+
+	parsed, err := ParseTask(string(task.Serialize()))
+
+	AssertEqual(t, err, nil, "Task with note could not be parsed")
+	AssertEqual(t, parsed.note, note, "Note did not survive a round trip")
+	AssertEqual(t, parsed.due.IsZero(), true, "Metadata was parsed from the note")
+	AssertEqual(t, FormatNote(note), `See https://example.com/a|b, due:2020/01/01/00/00\n  then: ping %s`, "Formatted note does not match")
+}
+
+func TestTaskTimeLog(t *testing.T) {
+	t.Run("parse", func(t *testing.T) {
+		log, err := ParseTimeLog("2024/05/07=15+2024/05/06=90")
+
+		AssertEqual(t, err, nil, "Valid time log was rejected")
+		AssertEqual(t, log.Total(), 105*time.Minute, "Total does not match")
+		AssertEqual(t, log.String(), "2024/05/06=90+2024/05/07=15", "Time log is not serialized in order")
+
+		for _, invalid := range []string{"", "2024/05/06", "2024/05/06=x", "yesterday=5"} {
+			_, err := ParseTimeLog(invalid)
+
+			AssertNotEqual(t, err, nil, "Invalid time log was accepted: "+invalid)
+		}
+	})
+
+	t.Run("midnight", func(t *testing.T) {
+		log := TimeLog{"2024/05/06": time.Hour}.Add(
+			time.Date(2024, 5, 6, 23, 30, 0, 0, time.UTC),
+			time.Date(2024, 5, 7, 0, 45, 0, 0, time.UTC),
+		)
+
+		AssertEqual(t, log.String(), "2024/05/06=90+2024/05/07=45", "Interval was not split at midnight")
+	})
+
+	t.Run("timer", func(t *testing.T) {
+		task, _ := ParseTask("A | creation:2024/05/06/07/08, finished:1970/01/01/00/00, tracked:2024/05/06=30, started:2024/05/07/09/00")
+		original := task.tracked
+
+		task.StopTimer(time.Date(2024, 5, 7, 10, 5, 0, 0, time.UTC))
+
+		AssertEqual(t, task.started.IsZero(), true, "Timer was not stopped")
+		AssertEqual(t, task.tracked.String(), "2024/05/06=30+2024/05/07=65", "Elapsed time was not tracked")
+		AssertEqual(t, original.String(), "2024/05/06=30", "Original time log was modified")
+		AssertEqual(t, FormatElapsed(task.tracked.Total()), "1h35m", "Formatted time does not match")
+	})
+}
+
+func TestTaskMetadata(t *testing.T) {
+	t.Run("unknown_fields", func(t *testing.T) {
+		line := "A | id:7b91fb49a85ea06bb0276e70984d602e62e95ea5, creation:2003/04/15/22/18, finished:2001/01/01/00/00, priority:B, color:red, x-future:{\"a\":1}, flagged\n"
+
+		task, err := ParseTask(line)
+
+		AssertEqual(t, err, nil, "Task with unknown fields could not be parsed")
+		AssertEqual(t, task.priority, "B", "Known field was not interpreted")
+		AssertEqual(t, len(task.attributes), 3, "Unknown fields were not kept")
+		AssertEqual(t, string(task.Serialize()), line, "Unknown fields were not written back verbatim")
+	})
+
+	t.Run("invalid_known_field", func(t *testing.T) {
+		task, _ := ParseTask("A | id:7b91fb49a85ea06bb0276e70984d602e62e95ea5, creation:2003/04/15/22/18, finished:2001/01/01/00/00, due:someday")
+
+		AssertEqual(t, task.due.IsZero(), true, "Invalid due date was parsed")
+		AssertEqual(t, len(task.attributes), 0, "Invalid known field was kept as an attribute")
+	})
+
+	t.Run("attributes", func(t *testing.T) {
+		task := NewTask("A")
+		copied := task
+
+		task.SetAttr("client", "ACME, Inc | 100%")
+		task.SetAttr("Client", "Other")
+		task.SetAttr("rate", "90")
+
+		value, ok := task.Attr("CLIENT")
+
+		AssertEqual(t, ok, true, "Attribute was not set")
+		AssertEqual(t, value, "Other", "Attribute was not replaced")
+		AssertEqual(t, len(copied.attributes), 0, "Attributes of a copy were modified")
+
+		task.SetAttr("client", "ACME, Inc | 100%")
+		parsed, _ := ParseTask(string(task.Serialize()))
+		value, _ = parsed.Attr("client")
+
+		AssertEqual(t, value, "ACME, Inc | 100%", "Attribute did not survive a round trip")
+
+		task.UnsetAttr("client")
+		_, ok = task.Attr("client")
+
+		AssertEqual(t, ok, false, "Attribute was not removed")
+		AssertEqual(t, len(task.attributes), 1, "Other attribute was removed")
+	})
+
+	t.Run("keys", func(t *testing.T) {
+		for _, arg := range []string{"due=tomorrow", "ID=1", "=x", "1st=x", "a b=c"} {
+			_, _, err := ParseAttributeArg(arg)
+
+			AssertNotEqual(t, err, nil, "Invalid attribute was accepted: "+arg)
+		}
+
+		key, value, err := ParseAttributeArg("client = ACME=1")
+
+		AssertEqual(t, err, nil, "Valid attribute was rejected")
+		AssertEqual(t, key+"|"+value, "client|ACME=1", "Attribute was not split at the first equals sign")
+	})
+}
+
+func TestTaskID(t *testing.T) {
+	t.Run("new", func(t *testing.T) {
+		one, two := NewTask("A"), NewTask("A")
+
+		AssertEqual(t, HashValuePattern.MatchString(one.hash), true, "ID is not compatible with t")
+		AssertNotEqual(t, one.hash, two.hash, "Identical tasks share an ID")
+	})
+
+	t.Run("plain_line", func(t *testing.T) {
+		task, _ := ParseTask("A")
+
+		AssertEqual(t, task.hash, hexHash("A"), "Task without metadata does not get the same ID everywhere")
+	})
+
+	t.Run("next_instance", func(t *testing.T) {
+		task := NewTask("A")
+		task.recurrence = "1w"
+
+		next, _ := task.NextInstance(time.Now())
+
+		AssertNotEqual(t, next.hash, task.hash, "Next instance shares the ID of the finished task")
+	})
+}
+
+func TestTaskSnooze(t *testing.T) {
+	now := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+
+	t.Run("metadata", func(t *testing.T) {
+		task, _ := ParseTask("A | id:7b91fb49a85ea06bb0276e70984d602e62e95ea5, creation:2024/05/06/07/08, finished:1970/01/01/00/00, wait:2024/05/07/00/00")
+
+		AssertEqual(t, task.wait, time.Date(2024, 5, 7, 0, 0, 0, 0, time.UTC), "Snooze was not parsed")
+		AssertEqual(t, string(task.Serialize()), "A | id:7b91fb49a85ea06bb0276e70984d602e62e95ea5, creation:2024/05/06/07/08, finished:1970/01/01/00/00, wait:2024/05/07/00/00\n", "Snooze was not serialized")
+		AssertEqual(t, task.IsSnoozed(now), true, "Task is not snoozed")
+		AssertEqual(t, task.IsSnoozed(task.wait), false, "Task did not wake up")
+	})
+
+	t.Run("views", func(t *testing.T) {
+		task := Task{wait: now.AddDate(0, 0, 1)}
+
+		for view, want := range map[string][2]bool{
+			HideSnoozed: {false, true},
+			ShowSnoozed: {true, false},
+			OnlySnoozed: {true, false},
+		} {
+			listed, hidden := snoozeFilter(task, view, now)
+
+			AssertEqual(t, [2]bool{listed, hidden}, want, "Snoozed task is not handled by view: "+view)
+		}
+
+		listed, _ := snoozeFilter(Task{}, OnlySnoozed, now)
+
+		AssertEqual(t, listed, false, "Task which is not snoozed was listed as snoozed")
+	})
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"os"
 	"regexp"
 	"strings"
 	"time"
@@ -10,15 +11,38 @@ import (
 // management mode.
 type TaskActions struct {
 	Add      func(string) `short:"a" long:"add" description:"Add a new task. Use when specifying multiple actions." value-name:"TEXT"`
+	AddSub   func(string) `short:"s" long:"add-sub" description:"Add a new subtask to the PARENT task" value-name:"PARENT/TEXT"`
 	Edit     func(string) `short:"e" long:"edit" description:"Replace an entire task/words from a task using sed syntax" value-name:"<SELECT,TEXT or SELECT/OLD/NEW>"`
 	Finish   func(string) `short:"f" long:"finish" description:"Mark TASK as finished" value-name:"SELECT"`
 	Remove   func(string) `short:"r" long:"remove" description:"Remove TASK from list" value-name:"SELECT"`
 	Wipe     func()       `short:"w" long:"wipe" description:"Remove all tasks"`
 	Complete func()       `short:"c" long:"complete" description:"Mark all tasks as finished"`
+	Priority func(string) `short:"p" long:"priority" description:"Set the priority of tasks from A (highest) to Z, or remove it using \"-\"" value-name:"SELECT/LEVEL"`
+	Due      func(string) `short:"d" long:"due" description:"Set the due date of tasks (YYYY/MM/DD[/HH/MM], YYYY-MM-DD, today, tomorrow or +N[hdwm]), or remove it using \"-\"" value-name:"SELECT/DATE"`
+	Note     func(string) `short:"n" long:"note" description:"Set the note of tasks, or edit the note of a task in $EDITOR if no TEXT is given. Use \"\\n\" for line breaks and \"-\" to remove the note." value-name:"SELECT[/TEXT]"`
+	Show     func(string) `long:"show" description:"Show every detail of tasks, including their notes, instead of listing tasks" value-name:"SELECT"`
+	Depends  func(string) `long:"depends" description:"Make tasks blocked by other tasks until those are finished, or remove their dependencies using \"-\"" value-name:"SELECT/SELECT"`
+	Start    func(string) `long:"start" description:"Start tracking the time spent on tasks, stopping every other timer" value-name:"SELECT"`
+	Stop     func()       `long:"stop" description:"Stop every running timer"`
+	Set      func(string) `long:"set" description:"Set an attribute of tasks" value-name:"SELECT/KEY=VALUE"`
+	Unset    func(string) `long:"unset" description:"Remove an attribute from tasks" value-name:"SELECT/KEY"`
+	Snooze   func(string) `long:"snooze" description:"Hide tasks and their subtasks until a date (same formats as --due), or wake them up using \"-\"" value-name:"SELECT/DATE"`
+	Every    func(string) `long:"every" description:"Make tasks recur after being finished (N[dwmy], daily, weekly, monthly, yearly or weekday; prefix with \"+\" for a fixed schedule), or stop it using \"-\"" value-name:"SELECT/RULE"`
+
+	WithPriority string `short:"P" long:"with-priority" description:"The priority of tasks added after this option" value-name:"LEVEL"`
+	Overdue      bool   `long:"overdue" description:"Only list overdue tasks. Exits with 103 if there are any."`
+	DueWithin    int    `long:"due-within" description:"Only list tasks due within the next DAYS days, including overdue ones" value-name:"DAYS"`
+	HideBlocked  bool   `long:"hide-blocked" description:"Do not list tasks blocked by active tasks"`
+	All          bool   `long:"all" description:"List snoozed tasks along with the others"`
+	Snoozed      bool   `long:"snoozed" description:"Only list snoozed tasks"`
+
+	Filters ListFilters `group:"Filter Options"`
 
 	Args struct {
 		TEXT []string
 	} `positional-args:"yes"`
+
+	skipListing bool // Set by actions printing their own output.
 }
 
 var taskActions TaskActions
@@ -28,24 +52,93 @@ var taskActions TaskActions
 func (a *TaskActions) Execute(args []string) error {
 	ListManager.EnsureInitialized(MainList)
 
+	// Finished subtasks are counted towards the progress of their parent.
+	if strings.Contains(OutputOptions.Format, "{progress}") {
+		ListManager.EnsureInitialized(DoneList)
+	}
+
 	// Join positional arguments and add them as a
 	// new task.
 	if len(a.Args.TEXT) != 0 {
-		text := strings.Join(a.Args.TEXT, " ")
-		MainList.Add(NewTask(text))
+		add(strings.Join(a.Args.TEXT, " "))
+	}
+
+	now := Now()
+
+	filters := a.Filters.TaskFilters()
+
+	if a.Overdue {
+		filters = append(filters, func(task Task) bool { return task.IsOverdue(now) })
+	}
+
+	if a.DueWithin > 0 {
+		within := time.Duration(a.DueWithin) * 24 * time.Hour
+
+		filters = append(filters, func(task Task) bool { return task.IsDueWithin(now, within) })
+	}
+
+	if a.HideBlocked {
+		filters = append(filters, func(task Task) bool { return !IsBlocked(task) })
+	}
+
+	snoozeView := HideSnoozed
+
+	switch {
+	case a.Snoozed:
+		snoozeView = OnlySnoozed
+	case a.All:
+		snoozeView = ShowSnoozed
 	}
 
-	MainList.Show(OutputOptions.Format)
+	if !a.skipListing {
+		MainList.Show(OutputOptions.Format, snoozeView, filters...)
+	}
 
 	ListManager.Save()
+
+	if a.Overdue && hasOverdue(now) {
+		os.Exit(StatusOverdue)
+	}
+
 	return nil
 }
 
+// hasOverdue reports whether any active task is overdue.
+func hasOverdue(now time.Time) bool {
+	for _, task := range MainList.tasks {
+		if task.IsOverdue(now) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // add adds a new task to the task list.
 func add(text string) {
 	ListManager.EnsureInitialized(MainList)
 
-	MainList.Add(NewTask(text))
+	task := createTask(text)
+
+	MainList.Add(task)
+	ListManager.Record(JournalAdd, task)
+}
+
+// createTask creates a task with the options applying to new tasks.
+func createTask(text string) Task {
+	task := NewTask(text)
+
+	if taskActions.WithPriority != "" {
+		priority, err := ParsePriority(taskActions.WithPriority)
+
+		if err != nil {
+			Error(ErrInvalidPriority, "Add", taskActions.WithPriority)
+		}
+
+		task.priority = priority
+	}
+
+	return task
 }
 
 // edit recognizes two formats (full replace and sed-style) and edits the
@@ -63,6 +156,22 @@ func edit(cmd string) {
 	}
 
 	index := MainList.InterpretSelectorPart(parts[0], MainList.OrderKeys())
+
+	if IsIDSelector(parts[0]) {
+		indexes, err := MainList.SelectTasks(parts[0])
+
+		if err != nil {
+			Error(ErrInvalidTaskID, err)
+		}
+
+		// Identical tasks created by older versions share an ID.
+		if len(indexes) != 1 {
+			Error(ErrEditInvalidSelector, cmd)
+		}
+
+		index = indexes[0]
+	}
+
 	oldTask, exists := MainList.tasks[index]
 
 	if !exists {
@@ -80,11 +189,11 @@ func edit(cmd string) {
 		newText = strings.ReplaceAll(oldTask.text, search, repl)
 	}
 
-	newTask := Task{
-		text:         newText,
-		creationDate: oldTask.creationDate,
-		finishedDate: oldTask.finishedDate,
-	}
+	// Keep the hash and metadata of the original task so the edit can be
+	// recognized when merging synced tasklists.
+	newTask := oldTask
+	newTask.text = newText
+	newTask.tags = ExtractTags(newText)
 
 	err := newTask.Validate()
 
@@ -92,17 +201,137 @@ func edit(cmd string) {
 		Error(ErrTaskValidation, "Edit", err)
 	}
 
-	MainList.tasks[index] = Task{
-		text:         newText,
-		creationDate: oldTask.creationDate,
-		finishedDate: oldTask.finishedDate,
+	MainList.tasks[index] = newTask
+	ListManager.Record(JournalEdit, newTask)
+
+	MainList.MarkModified()
+}
+
+// setPriority sets or removes the priority of the selected tasks.
+func setPriority(cmd string) {
+	ListManager.EnsureInitialized(MainList)
+	exitOnEmptyTasks("Priority")
+
+	var parts []string
+	recursiveSplit(cmd, &parts)
+
+	if len(parts) != 2 {
+		Error(ErrInvalidSelector, "Priority", cmd)
+	}
+
+	priority, err := ParsePriority(parts[1])
+
+	if err != nil {
+		Error(ErrInvalidPriority, "Priority", parts[1])
+	}
+
+	indexes, err := MainList.SelectTasks(parts[0])
+
+	ExitOnSelectorError(err, "Priority", parts[0])
+
+	for _, i := range indexes {
+		task, ok := MainList.tasks[i]
+
+		if !ok {
+			Error(ErrInvalidIndex, "Priority", i)
+		}
+
+		task.priority = priority
+
+		MainList.tasks[i] = task
+		ListManager.Record(JournalEdit, task)
+	}
+
+	MainList.MarkModified()
+}
+
+// setDue sets or removes the due date of the selected tasks.
+func setDue(cmd string) {
+	ListManager.EnsureInitialized(MainList)
+	exitOnEmptyTasks("Due")
+
+	var parts []string
+	recursiveSplit(cmd, &parts)
+
+	// Dates may contain slashes themselves.
+	if len(parts) < 2 {
+		Error(ErrInvalidSelector, "Due", cmd)
+	}
+
+	value := strings.Join(parts[1:], "/")
+
+	var due time.Time
+
+	if value != "-" && !strings.EqualFold(value, "none") {
+		var err error
+
+		if due, err = ParseDateArg(value, true); err != nil {
+			Error(ErrInvalidDate, "Due", value)
+		}
+	}
+
+	indexes, err := MainList.SelectTasks(parts[0])
+
+	ExitOnSelectorError(err, "Due", parts[0])
+
+	for _, i := range indexes {
+		task, ok := MainList.tasks[i]
+
+		if !ok {
+			Error(ErrInvalidIndex, "Due", i)
+		}
+
+		task.due = due
+
+		MainList.tasks[i] = task
+		ListManager.Record(JournalEdit, task)
+	}
+
+	MainList.MarkModified()
+}
+
+// setRecurrence sets or removes the recurrence rule of the selected tasks.
+func setRecurrence(cmd string) {
+	ListManager.EnsureInitialized(MainList)
+	exitOnEmptyTasks("Every")
+
+	var parts []string
+	recursiveSplit(cmd, &parts)
+
+	if len(parts) != 2 {
+		Error(ErrInvalidSelector, "Every", cmd)
+	}
+
+	rule, err := NormalizeRecurrence(parts[1])
+
+	if err != nil {
+		Error(ErrInvalidRecurrence, "Every", parts[1])
+	}
+
+	indexes, err := MainList.SelectTasks(parts[0])
+
+	ExitOnSelectorError(err, "Every", parts[0])
+
+	for _, i := range indexes {
+		task, ok := MainList.tasks[i]
+
+		if !ok {
+			Error(ErrInvalidIndex, "Every", i)
+		}
+
+		task.recurrence = rule
+
+		MainList.tasks[i] = task
+		ListManager.Record(JournalEdit, task)
 	}
 
 	MainList.MarkModified()
 }
 
 // finish initializes a "finished tasks" list, adds tasks to it, then removes
-// the tasks and writes the donelist to file.
+// the tasks and writes the donelist to file. Subtasks are finished along with
+// their parent. Recurring tasks are replaced by their next instance. Finishing
+// blocked tasks is allowed, but warned about.
 func finish(selector string) {
 	ListManager.EnsureInitialized(MainList)
 	exitOnEmptyTasks("Finish")
@@ -110,29 +339,50 @@ func finish(selector string) {
 
 	indexes, err := MainList.SelectTasks(selector)
 
-	if err != nil {
-		Error(ErrInvalidSelector, "Finish", selector)
-	}
+	ExitOnSelectorError(err, "Finish", selector)
+
+	indexes = MainList.Subtrees(indexes)
+
+	warnBlocked(indexes)
 
 	for _, i := range indexes {
 		task := MainList.tasks[i]
 
-		task.finishedDate = time.Now()
+		task.StopTimer(Now())
+		task.finishedDate = Now()
 		DoneList.Add(task)
+		ListManager.Record(JournalFinish, task)
+
+		if task.recurrence == "" {
+			continue
+		}
+
+		next, err := task.NextInstance(Now())
+
+		if err != nil {
+			Error(ErrInvalidRecurrence, "Finish", task.recurrence)
+		}
+
+		MainList.Add(next)
+		ListManager.Record(JournalAdd, next)
 	}
 
 	MainList.Remove(indexes)
 }
 
-// remove removes a task from the tasklist.
+// remove removes a task and its subtasks from the tasklist.
 func remove(selector string) {
 	ListManager.EnsureInitialized(MainList)
 	exitOnEmptyTasks("Remove")
 
 	indexes, err := MainList.SelectTasks(selector)
 
-	if err != nil {
-		Error(ErrInvalidSelector, "Remove", selector)
+	ExitOnSelectorError(err, "Remove", selector)
+
+	indexes = MainList.Subtrees(indexes)
+
+	for _, i := range indexes {
+		ListManager.Record(JournalRemove, MainList.tasks[i])
 	}
 
 	MainList.Remove(indexes)
@@ -143,6 +393,10 @@ func wipeTasks() {
 	ListManager.EnsureInitialized(MainList)
 	exitOnEmptyTasks("Wipe")
 
+	for _, task := range MainList.tasks {
+		ListManager.Record(JournalRemove, task)
+	}
+
 	MainList.tasks = make(map[int]Task)
 
 	MainList.MarkModified()
@@ -160,11 +414,23 @@ func complete() {
 // respective action and adds the subcommand to the global argument parser.
 func init() {
 	taskActions.Add = add
+	taskActions.AddSub = addSub
 	taskActions.Edit = edit
 	taskActions.Finish = finish
 	taskActions.Remove = remove
 	taskActions.Wipe = wipeTasks
 	taskActions.Complete = complete
+	taskActions.Priority = setPriority
+	taskActions.Due = setDue
+	taskActions.Every = setRecurrence
+	taskActions.Snooze = setSnooze
+	taskActions.Depends = setDependencies
+	taskActions.Set = setAttribute
+	taskActions.Unset = unsetAttribute
+	taskActions.Start = startTimer
+	taskActions.Stop = stopTimers
+	taskActions.Note = setNote
+	taskActions.Show = showTasks
 
 	GlobalParser.AddCommand("tasks", "Manage active tasks", "", &taskActions)
 }
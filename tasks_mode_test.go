@@ -1,7 +1,12 @@
 package main
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestAdd(t *testing.T) {
@@ -153,3 +158,460 @@ func TestComplete(t *testing.T) {
 	AssertEmptyTasklist(t, MainList)
 	AssertNonEmptyTasklist(t, DoneList)
 }
+
+func TestPriority(t *testing.T) {
+	InitNumberedTestingEnv(&MainList)
+
+	t.Run("set", func(t *testing.T) {
+		setPriority("2,4/a")
+
+		AssertEqual(t, MainList.tasks[2].priority, "A", "Priority of task 2 was not set")
+		AssertEqual(t, MainList.tasks[4].priority, "A", "Priority of task 4 was not set")
+		AssertEqual(t, MainList.tasks[3].priority, "", "Priority of an unselected task was set")
+	})
+
+	t.Run("sort", func(t *testing.T) {
+		setPriority("5/C")
+
+		keys := MainList.SortKeys(SortPriority)
+
+		AssertEqual(t, fmt.Sprint(keys), "[2 4 5 1 3 6 7]", "Tasks are not ordered by priority")
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		setPriority("2/-")
+
+		AssertEqual(t, MainList.tasks[2].priority, "", "Priority was not removed")
+	})
+
+	t.Run("with_priority", func(t *testing.T) {
+		taskActions.WithPriority = "b"
+		defer func() { taskActions.WithPriority = "" }()
+
+		add("eight")
+
+		AssertEqual(t, MainList.tasks[8].priority, "B", "Priority of the added task was not set")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		AssertExitError(t, "TestPriority/invalid", ErrInvalidPriority, func() {
+			setPriority("1/high")
+		})
+	})
+}
+
+func TestDue(t *testing.T) {
+	InitNumberedTestingEnv(&MainList)
+
+	t.Run("set", func(t *testing.T) {
+		setDue("2/2003/04/20/17/00")
+		setDue("3/+3d")
+
+		AssertEqual(t, MainList.tasks[2].due, time.Date(2003, 4, 20, 17, 0, 0, 0, time.UTC), "Due date of task 2 was not set")
+		AssertEqual(t, MainList.tasks[3].due.After(Now()), true, "Due date of task 3 is not in the future")
+		AssertEqual(t, hasOverdue(Now()), true, "Overdue task was not detected")
+	})
+
+	t.Run("sort", func(t *testing.T) {
+		keys := MainList.SortKeys(SortDue)
+
+		AssertEqual(t, fmt.Sprint(keys), "[2 3 1 4 5 6 7]", "Tasks are not ordered by due date")
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		setDue("2/-")
+
+		AssertEqual(t, MainList.tasks[2].due.IsZero(), true, "Due date was not removed")
+		AssertEqual(t, hasOverdue(Now()), false, "Task without due date is overdue")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		AssertExitError(t, "TestDue/invalid", ErrInvalidDate, func() {
+			setDue("1/someday")
+		})
+	})
+}
+
+func TestTags(t *testing.T) {
+	InitEmptyTestingEnv(&MainList)
+	InitEmptyTestingEnv(&DoneList)
+
+	add("ship +release @work")
+	add("write notes +release")
+	add("call mom @home")
+
+	t.Run("filter", func(t *testing.T) {
+		filters := ListFilters{Tags: []string{"release"}, Contexts: []string{"@work"}}.TaskFilters()
+
+		var matches []string
+
+		for _, task := range MainList.tasks {
+			matched := true
+
+			for _, filter := range filters {
+				matched = matched && filter(task)
+			}
+
+			if matched {
+				matches = append(matches, task.text)
+			}
+		}
+
+		AssertEqual(t, fmt.Sprint(matches), "[ship +release @work]", "Tasks were not filtered by tag and context")
+	})
+
+	t.Run("select", func(t *testing.T) {
+		finish("+release")
+
+		AssertEqual(t, len(MainList.tasks), 1, "Tagged tasks were not finished")
+		AssertEqual(t, len(DoneList.tasks), 2, "Tagged tasks were not moved to the DoneList")
+		AssertEqual(t, countTags(DoneList)["+release"], 2, "Finished tags were not counted")
+	})
+}
+
+func TestSubtasks(t *testing.T) {
+	InitEmptyTestingEnv(&MainList)
+	InitEmptyTestingEnv(&DoneList)
+
+	for _, text := range []string{"one", "two", "three", "four", "five", "six", "seven"} {
+		add(text)
+	}
+
+	addSub("1/sub of one")
+	addSub("8/sub of sub")
+	addSub("2/sub of two")
+
+	t.Run("tree", func(t *testing.T) {
+		keys, depths := MainList.TreeKeys(SortIndex)
+
+		AssertEqual(t, fmt.Sprint(keys), "[1 8 9 2 10 3 4 5 6 7]", "Subtasks are not listed after their parent")
+		AssertEqual(t, depths[9], 2, "Depth of nested subtask does not match")
+		AssertEqual(t, Progress(MainList.tasks[1]), "0/1", "Progress does not match")
+	})
+
+	t.Run("finish", func(t *testing.T) {
+		finish("1")
+
+		AssertDeletedTask(t, 1, MainList)
+		AssertDeletedTask(t, 8, MainList)
+		AssertDeletedTask(t, 9, MainList)
+		AssertEqual(t, len(DoneList.tasks), 3, "Subtasks were not finished along with their parent")
+	})
+
+	t.Run("progress", func(t *testing.T) {
+		finish("10")
+
+		AssertEqual(t, Progress(MainList.tasks[2]), "1/1", "Finished subtask was not counted")
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		restore("3")
+
+		AssertEqual(t, len(DoneList.tasks), 1, "Subtask was restored without its parent")
+		AssertMainTaskText(t, 8, "one")
+		AssertMainTaskText(t, 9, "sub of one")
+		AssertMainTaskText(t, 10, "sub of sub")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		AssertExitError(t, "TestSubtasks/invalid", ErrInvalidIndex, func() {
+			addSub("99/orphan")
+		})
+	})
+}
+
+func TestRecurrence(t *testing.T) {
+	InitEmptyTestingEnv(&MainList)
+	InitEmptyTestingEnv(&DoneList)
+
+	add("rotate on-call")
+	add("water plants")
+
+	t.Run("set", func(t *testing.T) {
+		setRecurrence("1/+weekly")
+		setRecurrence("2/2d")
+
+		AssertEqual(t, MainList.tasks[1].recurrence, "+weekly", "Recurrence was not set")
+	})
+
+	t.Run("finish", func(t *testing.T) {
+		setDue("1/2024/05/06/09/00")
+		finish("1-2")
+
+		AssertEqual(t, len(DoneList.tasks), 2, "Recurring tasks were not finished")
+		AssertMainTaskText(t, 3, "rotate on-call")
+		AssertMainTaskText(t, 4, "water plants")
+		AssertEqual(t, MainList.tasks[3].due.Weekday(), time.Monday, "Fixed schedule was not kept")
+		AssertEqual(t, MainList.tasks[3].due.After(Now()), true, "Next instance is not due in the future")
+		AssertEqual(t, MainList.tasks[4].due.Sub(Now()) > 24*time.Hour, true, "Next instance is not due after the interval")
+		AssertEqual(t, MainList.tasks[4].recurrence, "2d", "Next instance does not recur")
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		setRecurrence("3/-")
+		finish("3")
+
+		AssertEqual(t, len(MainList.tasks), 1, "Task recurred after removing its recurrence")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		AssertExitError(t, "TestRecurrence/invalid", ErrInvalidRecurrence, func() {
+			setRecurrence("4/sometimes")
+		})
+	})
+}
+
+func TestNote(t *testing.T) {
+	InitEmptyTestingEnv(&MainList)
+
+	add("one")
+	add("two")
+
+	t.Run("text", func(t *testing.T) {
+		setNote(`1-2/line one\nhttps://example.com/path`)
+
+		AssertEqual(t, MainList.tasks[1].note, "line one\nhttps://example.com/path", "Note was not set")
+		AssertEqual(t, MainList.tasks[2].note, MainList.tasks[1].note, "Note was not set for every selected task")
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		setNote("2/-")
+
+		AssertEqual(t, MainList.tasks[2].note, "", "Note was not removed")
+	})
+
+	t.Run("editor", func(t *testing.T) {
+		editor := filepath.Join(t.TempDir(), "editor")
+
+		os.WriteFile(editor, []byte("#!/bin/sh\necho \"$(cat \"$1\") edited\" > \"$1\"\n"), 0755)
+		t.Setenv("VISUAL", "")
+		t.Setenv("EDITOR", editor)
+
+		setNote("1")
+
+		AssertEqual(t, MainList.tasks[1].note, "line one\nhttps://example.com/path edited", "Note was not edited")
+	})
+
+	t.Run("editor_multiple", func(t *testing.T) {
+		AssertExitError(t, "TestNote/editor_multiple", ErrInvalidSelector, func() {
+			setNote("1,2")
+		})
+	})
+}
+
+func TestDependencies(t *testing.T) {
+	InitEmptyTestingEnv(&MainList)
+	InitEmptyTestingEnv(&DoneList)
+
+	add("spec")
+	add("implement")
+	add("release")
+
+	t.Run("set", func(t *testing.T) {
+		setDependencies("2/1")
+		setDependencies("3/1,2")
+		setDependencies("3/3")
+
+		AssertEqual(t, len(MainList.tasks[3].blockedBy), 2, "Dependencies were not set")
+		AssertEqual(t, IsBlocked(MainList.tasks[2]), true, "Blocked task was not detected")
+		AssertEqual(t, IsBlocked(MainList.tasks[1]), false, "Task without dependencies is blocked")
+		AssertEqual(t, blockedBy(MainList.tasks[3]), "1,2", "Blocking tasks do not match")
+
+		task := MainList.tasks[3]
+		parsed, _ := ParseTask(string(task.Serialize()))
+
+		AssertEqual(t, fmt.Sprint(parsed.blockedBy), fmt.Sprint(task.blockedBy), "Dependencies did not survive a round trip")
+	})
+
+	t.Run("finish", func(t *testing.T) {
+		finish("1")
+
+		AssertEqual(t, IsBlocked(MainList.tasks[2]), false, "Task is blocked by a finished task")
+		AssertEqual(t, blockedBy(MainList.tasks[3]), "1", "Blocking tasks were not renumbered")
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		setDependencies("3/-")
+
+		AssertEqual(t, len(MainList.tasks[3].blockedBy), 0, "Dependencies were not removed")
+	})
+
+	t.Run("dot", func(t *testing.T) {
+		AssertEqual(t, dotString(`say "hi" \o/`), `"say \"hi\" \\o/"`, "DOT string was not escaped")
+	})
+}
+
+func TestTimeTracking(t *testing.T) {
+	InitEmptyTestingEnv(&MainList)
+	InitEmptyTestingEnv(&DoneList)
+
+	add("one")
+	add("two")
+
+	t.Run("start", func(t *testing.T) {
+		startTimer("1")
+
+		AssertEqual(t, MainList.tasks[1].started.IsZero(), false, "Timer was not started")
+
+		// Pretend the timer has been running for an hour.
+		task := MainList.tasks[1]
+		task.started = task.started.Add(-time.Hour)
+		MainList.tasks[1] = task
+
+		startTimer("2")
+
+		AssertEqual(t, MainList.tasks[1].started.IsZero(), true, "Timer of other task was not stopped")
+		AssertEqual(t, MainList.tasks[1].tracked.Total() >= time.Hour, true, "Elapsed time was not tracked")
+		AssertEqual(t, MainList.tasks[2].started.IsZero(), false, "Timer was not started")
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		stopTimers()
+
+		AssertEqual(t, MainList.tasks[2].started.IsZero(), true, "Timer was not stopped")
+	})
+
+	t.Run("finish", func(t *testing.T) {
+		finish("1")
+
+		AssertEqual(t, DoneList.tasks[1].tracked.Total() >= time.Hour, true, "Tracked time was not kept when finishing")
+	})
+}
+
+func TestAttributes(t *testing.T) {
+	InitEmptyTestingEnv(&MainList)
+
+	add("one")
+	add("two")
+
+	t.Run("set", func(t *testing.T) {
+		setAttribute("1-2/client=ACME")
+		setAttribute("2/client=Other/Corp")
+
+		value, _ := MainList.tasks[2].Attr("client")
+
+		AssertEqual(t, value, "Other/Corp", "Attribute was not set")
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		AssertEqual(t, attrFilter("client=acme")(MainList.tasks[1]), true, "Attribute value was not matched")
+		AssertEqual(t, attrFilter("client=acme")(MainList.tasks[2]), false, "Different attribute value was matched")
+		AssertEqual(t, attrFilter("client")(MainList.tasks[2]), true, "Attribute was not matched")
+	})
+
+	t.Run("unset", func(t *testing.T) {
+		unsetAttribute("1/client")
+
+		AssertEqual(t, attrFilter("client")(MainList.tasks[1]), false, "Attribute was not removed")
+	})
+
+	t.Run("reserved", func(t *testing.T) {
+		AssertExitError(t, "TestAttributes/reserved", ErrInvalidAttribute, func() {
+			setAttribute("1/priority=A")
+		})
+	})
+}
+
+func TestIDs(t *testing.T) {
+	InitEmptyTestingEnv(&MainList)
+
+	add("one")
+	add("one")
+	add("two @cafe")
+	add("three")
+
+	for index, id := range map[int]string{
+		1: "3fa9000000000000000000000000000000000001",
+		2: "3fa9100000000000000000000000000000000002",
+		4: "cafe000000000000000000000000000000000004",
+	} {
+		task := MainList.tasks[index]
+		task.hash = id
+		MainList.tasks[index] = task
+	}
+
+	t.Run("prefix", func(t *testing.T) {
+		indexes, err := MainList.SelectTasks("ID:3FA90")
+
+		AssertEqual(t, err, nil, "Unique prefix was rejected")
+		AssertEqual(t, fmt.Sprint(indexes), "[1]", "Wrong task was selected")
+
+		indexes, _ = MainList.SelectTasks("id:3fa91, id:" + MainList.tasks[3].hash)
+
+		AssertEqual(t, fmt.Sprint(indexes), "[2 3]", "Wrong tasks were selected")
+	})
+
+	t.Run("context", func(t *testing.T) {
+		indexes, _ := MainList.SelectTasks("@cafe")
+
+		AssertEqual(t, fmt.Sprint(indexes), "[3]", "Context looking like an ID did not select the tagged task")
+
+		indexes, _ = MainList.SelectTasks("id:cafe")
+
+		AssertEqual(t, fmt.Sprint(indexes), "[4]", "ID looking like a context did not select the task")
+	})
+
+	for name, selector := range map[string]string{
+		"ambiguous": "id:3fa9",
+		"unknown":   "id:ffff",
+		"short":     "id:3fa",
+		"mixed":     "id:3fa90,2",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := MainList.SelectTasks(selector)
+
+			if err == nil || !strings.Contains(err.Error(), "ID") {
+				t.Fatalf("Invalid ID selector \"%s\" did not fail with its reason: %v", selector, err)
+			}
+		})
+	}
+
+	t.Run("command", func(t *testing.T) {
+		AssertExitError(t, "TestIDs/command", ErrInvalidTaskID, func() {
+			setSnooze("id:3fa9/+3d")
+		})
+	})
+
+	t.Run("edit", func(t *testing.T) {
+		edit("id:3fa91/changed")
+
+		AssertMainTaskText(t, 2, "changed")
+		AssertEqual(t, MainList.tasks[2].hash, "3fa9100000000000000000000000000000000002", "ID changed on edit")
+	})
+}
+
+func TestSnooze(t *testing.T) {
+	InitEmptyTestingEnv(&MainList)
+
+	add("one")
+	add("two")
+	addSub("1/sub of one")
+
+	now := Now()
+
+	t.Run("snooze", func(t *testing.T) {
+		setSnooze("1/+3d")
+
+		AssertEqual(t, MainList.tasks[1].IsSnoozed(now), true, "Task was not snoozed")
+		AssertEqual(t, MainList.tasks[3].IsSnoozed(now), true, "Subtask was not snoozed along with its parent")
+		AssertEqual(t, MainList.tasks[2].IsSnoozed(now), false, "Other task was snoozed")
+	})
+
+	t.Run("past", func(t *testing.T) {
+		setSnooze("2/2020/01/01")
+
+		AssertEqual(t, MainList.tasks[2].IsSnoozed(now), false, "Task snoozed until a past date is hidden")
+	})
+
+	t.Run("wake", func(t *testing.T) {
+		setSnooze("3/-")
+
+		AssertEqual(t, MainList.tasks[3].wait.IsZero(), true, "Snooze was not removed")
+	})
+
+	t.Run("invalid_date", func(t *testing.T) {
+		AssertExitError(t, "TestSnooze/invalid_date", ErrInvalidDate, func() {
+			setSnooze("1/later")
+		})
+	})
+}
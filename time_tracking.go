@@ -0,0 +1,273 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeLog holds the time tracked for a task per day, keyed by the date in
+// DateFormat.
+type TimeLog map[string]time.Duration
+
+// ParseTimeLog parses a time log stored in the taskline metadata, e.g.:
+// "2024/05/06=90+2024/05/07=15", where the values are minutes.
+func ParseTimeLog(s string) (TimeLog, error) {
+	log := make(TimeLog)
+
+	for _, entry := range strings.Split(s, "+") {
+		day, minutes, ok := strings.Cut(entry, "=")
+
+		if !ok {
+			return nil, fmt.Errorf("invalid entry \"%s\"", entry)
+		}
+
+		if _, err := time.Parse(DateFormat, day); err != nil {
+			return nil, err
+		}
+
+		n, err := strconv.Atoi(minutes)
+
+		if err != nil {
+			return nil, err
+		}
+
+		log[day] += time.Duration(n) * time.Minute
+	}
+
+	return log, nil
+}
+
+// String converts the time log to the form stored in the taskline metadata.
+func (l TimeLog) String() string {
+	var entries []string
+
+	for _, day := range l.Days() {
+		entries = append(entries, fmt.Sprintf("%s=%d", day, l[day]/time.Minute))
+	}
+
+	return strings.Join(entries, "+")
+}
+
+// Days returns the days in the time log in chronological order.
+func (l TimeLog) Days() (days []string) {
+	for day := range l {
+		days = append(days, day)
+	}
+
+	sort.Strings(days)
+
+	return
+}
+
+// Total returns the time tracked on every day.
+func (l TimeLog) Total() (total time.Duration) {
+	for _, d := range l {
+		total += d
+	}
+
+	return
+}
+
+// Add returns a copy of the time log with an interval added. Intervals
+// spanning midnight are split between the days.
+func (l TimeLog) Add(start time.Time, end time.Time) TimeLog {
+	log := make(TimeLog, len(l)+1)
+
+	for day, d := range l {
+		log[day] = d
+	}
+
+	for start.Before(end) {
+		stop := time.Date(start.Year(), start.Month(), start.Day()+1, 0, 0, 0, 0, start.Location())
+
+		if end.Before(stop) {
+			stop = end
+		}
+
+		log[start.Format(DateFormat)] += stop.Sub(start)
+		start = stop
+	}
+
+	return log
+}
+
+// TrackedTime returns the time log of a task including the running timer.
+func (t Task) TrackedTime(now time.Time) TimeLog {
+	if t.started.IsZero() {
+		return t.tracked
+	}
+
+	return t.tracked.Add(t.started, now)
+}
+
+// StopTimer stops the running timer of a task and adds the elapsed time to its
+// time log.
+func (t *Task) StopTimer(now time.Time) {
+	if t.started.IsZero() {
+		return
+	}
+
+	t.tracked = t.TrackedTime(now)
+	t.started = time.Time{}
+}
+
+// FormatElapsed formats tracked time as hours and minutes, e.g.: "1h05m".
+func FormatElapsed(d time.Duration) string {
+	d = d.Truncate(time.Minute)
+
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+
+	return fmt.Sprintf("%dh%02dm", d/time.Hour, d%time.Hour/time.Minute)
+}
+
+// formatRunning returns how long the timer of a task has been running, or an
+// empty string if it is not running.
+func formatRunning(task Task, now time.Time) string {
+	if task.started.IsZero() {
+		return ""
+	}
+
+	return FormatElapsed(now.Sub(task.started))
+}
+
+// startTimer starts the timer of the selected tasks. Running timers of other
+// tasks are stopped, so time is not tracked twice.
+func startTimer(selector string) {
+	ListManager.EnsureInitialized(MainList)
+	exitOnEmptyTasks("Start")
+
+	indexes, err := MainList.SelectTasks(selector)
+
+	ExitOnSelectorError(err, "Start", selector)
+
+	now := Now()
+	selected := make(map[int]bool)
+
+	for _, i := range indexes {
+		if _, ok := MainList.tasks[i]; !ok {
+			Error(ErrInvalidIndex, "Start", i)
+		}
+
+		selected[i] = true
+	}
+
+	for _, i := range MainList.OrderKeys() {
+		task := MainList.tasks[i]
+
+		switch {
+		case selected[i] && task.started.IsZero():
+			task.started = now
+		case !selected[i] && !task.started.IsZero():
+			task.StopTimer(now)
+		default:
+			continue
+		}
+
+		MainList.tasks[i] = task
+		ListManager.Record(JournalEdit, task)
+		MainList.MarkModified()
+	}
+}
+
+// stopTimers stops every running timer.
+func stopTimers() {
+	ListManager.EnsureInitialized(MainList)
+
+	now := Now()
+
+	for _, i := range MainList.OrderKeys() {
+		task := MainList.tasks[i]
+
+		if task.started.IsZero() {
+			continue
+		}
+
+		task.StopTimer(now)
+
+		MainList.tasks[i] = task
+		ListManager.Record(JournalEdit, task)
+		MainList.MarkModified()
+	}
+}
+
+// TimesheetParams holds the command line arguments for the `timesheet`
+// command.
+type TimesheetParams struct {
+	Since string `long:"since" description:"Only count time tracked on or after DATE (YYYY/MM/DD, YYYY-MM-DD, today or +N[hdwm])" value-name:"DATE"`
+}
+
+// Execute sums the time tracked for active and finished tasks per task and
+// per day.
+func (a *TimesheetParams) Execute(args []string) error {
+	ListManager.EnsureInitialized(MainList)
+	ListManager.EnsureInitialized(DoneList)
+
+	var since string
+
+	if a.Since != "" {
+		date, err := ParseDateArg(a.Since, false)
+
+		if err != nil {
+			Error(ErrInvalidDate, "Timesheet", a.Since)
+		}
+
+		since = date.Format(DateFormat)
+	}
+
+	now := Now()
+	perDay := make(TimeLog)
+
+	var total time.Duration
+
+	fmt.Println("Per task:")
+
+	for _, tl := range []*Tasklist{MainList, DoneList} {
+		for _, index := range tl.OrderKeys() {
+			task := tl.tasks[index]
+
+			var sum time.Duration
+
+			for day, d := range task.TrackedTime(now) {
+				if day >= since {
+					sum += d
+					perDay[day] += d
+				}
+			}
+
+			if sum < time.Minute {
+				continue
+			}
+
+			label := task.text
+
+			if tl == DoneList {
+				label += " (finished)"
+			}
+
+			fmt.Printf("  %8s  %s\n", FormatElapsed(sum), label)
+
+			total += sum
+		}
+	}
+
+	fmt.Printf("  %8s  Total\n", FormatElapsed(total))
+
+	fmt.Println("\nPer day:")
+
+	for _, day := range perDay.Days() {
+		fmt.Printf("  %s  %8s\n", day, FormatElapsed(perDay[day]))
+	}
+
+	return nil
+}
+
+// init gets called when the package is imported; adds the command to the
+// global argument parser.
+func init() {
+	GlobalParser.AddCommand("timesheet", "Sum the time tracked per task and per day", "", &TimesheetParams{})
+}
@@ -19,6 +19,10 @@ var (
 	// SyncfilePath holds the path to the current Syncfile. The path is derived
 	// from TaskfilePath like so: "./.{TaskfilePath}.sync".
 	SyncfilePath string
+	// JournalfilePath holds the path to the journal of operations made while
+	// a synced tasklist could not be loaded from the network. The path is
+	// derived from TaskfilePath like so: "./.{TaskfilePath}.journal".
+	JournalfilePath string
 )
 
 var (
@@ -43,6 +47,7 @@ func InitPathVariables(taskfilePath string) {
 	TaskfilePath = taskfilePath
 	DonefilePath = GetMetafilePath(".done", TaskfilePath)
 	SyncfilePath = GetMetafilePath(".sync", TaskfilePath)
+	JournalfilePath = GetMetafilePath(".journal", TaskfilePath)
 }
 
 func main() {
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"path"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -35,3 +38,93 @@ func EnsureTrailingSlash(s *string) {
 		*s += "/"
 	}
 }
+
+// Now returns the current wall clock time in the form task dates are stored
+// in, so the two can be compared.
+func Now() time.Time {
+	return StripNanoFromTime(time.Now())
+}
+
+// relativeDatePattern matches relative dates like "+3d" or "+2w".
+var relativeDatePattern = regexp.MustCompile(`^\+(\d+)([hdwm])$`)
+
+// ParseDateArg parses a date given on the command line. Accepted forms are
+// FullDateFormat, DateFormat, "YYYY-MM-DD", "today", "tomorrow" and relative
+// dates like "+3d" (hours, days, weeks or months). Dates without a time refer
+// to the start of the day, or its last minute if endOfDay is set.
+func ParseDateArg(value string, endOfDay bool) (time.Time, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	now := Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var date time.Time
+
+	switch value {
+	case "today":
+		date = today
+	case "tomorrow":
+		date = today.AddDate(0, 0, 1)
+	default:
+		if m := relativeDatePattern.FindStringSubmatch(value); m != nil {
+			n, _ := strconv.Atoi(m[1])
+
+			switch m[2] {
+			case "h":
+				return now.Add(time.Duration(n) * time.Hour).Truncate(time.Minute), nil
+			case "d":
+				date = today.AddDate(0, 0, n)
+			case "w":
+				date = today.AddDate(0, 0, 7*n)
+			case "m":
+				date = today.AddDate(0, n, 0)
+			}
+
+			break
+		}
+
+		if full, err := time.Parse(FullDateFormat, value); err == nil {
+			return full, nil
+		}
+
+		var err error
+
+		if date, err = time.Parse(DateFormat, value); err != nil {
+			if date, err = time.Parse("2006-01-02", value); err != nil {
+				return time.Time{}, fmt.Errorf("invalid date \"%s\"", value)
+			}
+		}
+	}
+
+	if endOfDay {
+		date = date.Add(24*time.Hour - time.Minute)
+	}
+
+	return date, nil
+}
+
+// FormatDuration formats a duration using its largest unit, e.g.: "3d", "5h"
+// or "20m".
+func FormatDuration(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	default:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+}
+
+// DueIn describes when a task is due relative to now, e.g.: "in 3d" or "2h
+// overdue". Empty if the task has no due date.
+func DueIn(due time.Time, now time.Time) string {
+	if due.IsZero() {
+		return ""
+	}
+
+	if due.Before(now) {
+		return FormatDuration(now.Sub(due)) + " overdue"
+	}
+
+	return "in " + FormatDuration(due.Sub(now))
+}
@@ -190,3 +190,8 @@ func AssertExitError(t *testing.T, testName string, wantedExitCode int, test fun
 func AssertExitSuccess(t *testing.T, testName string, test func()) {
 	AssertExitError(t, testName, -1, test)
 }
+
+func init() {
+	// Keep retries of failed sync requests from slowing down the tests.
+	RetryBaseDelay = time.Millisecond
+}
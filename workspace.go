@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// WorkspaceSyncfileName is the name of the syncfile of a workspace. It is
+// placed in the synced directory.
+const WorkspaceSyncfileName = ".workspace.sync"
+
+// SyncedList is a single tasklist as stored on the Sync service. The last
+// synced state of every list is kept in the syncfile as the common ancestor
+// for merging.
+type SyncedList struct {
+	Contents     string `json:"contents"`
+	DoneContents string `json:"doneContents"`
+}
+
+// WorkspacePayloadVersion is the version of the workspace format written by
+// tx. Every list in a workspace is stored as a Payload.
+const WorkspacePayloadVersion = 1
+
+// WorkspacePayload holds the lists of a workspace, keyed by the filename of
+// their taskfile.
+type WorkspacePayload struct {
+	Lists map[string]SyncedList
+}
+
+// workspaceEnvelope is the JSON object stored on the Sync service for a
+// workspace.
+type workspaceEnvelope struct {
+	Version int                        `json:"version"`
+	Lists   map[string]json.RawMessage `json:"lists"`
+}
+
+// Workspace is a directory whose taskfiles are all synced under a single Sync
+// ID. Lists created or deleted on one device are created or deleted on the
+// others as well.
+type Workspace struct {
+	dir string
+	sf  *Syncfile        // The syncfile, written once the workspace is synced.
+	tm  *TasklistManager // Holds the sync settings parsed from the syncfile.
+}
+
+// WorkspaceSyncfilePath returns the path of the syncfile of a workspace.
+func WorkspaceSyncfilePath(dir string) string {
+	return filepath.Join(dir, WorkspaceSyncfileName)
+}
+
+// FindWorkspace returns the workspace the taskfile belongs to, or nil if its
+// directory is not synced as a workspace.
+func FindWorkspace(taskfilePath string) *Workspace {
+	ws := LoadWorkspace(filepath.Dir(taskfilePath))
+
+	if ws.tm.syncID == "" {
+		return nil
+	}
+
+	return ws
+}
+
+// LoadWorkspace reads the sync settings of a workspace from its syncfile.
+func LoadWorkspace(dir string) *Workspace {
+	return newWorkspace(dir, ReadSyncfile(WorkspaceSyncfilePath(dir)))
+}
+
+// newWorkspace creates a workspace using the settings of a syncfile, which
+// may not have been written yet.
+func newWorkspace(dir string, sf *Syncfile) *Workspace {
+	ws := &Workspace{dir: dir, sf: sf, tm: &TasklistManager{}}
+	ws.tm.applySyncfile(sf)
+
+	return ws
+}
+
+// IsWorkspaceList reports whether a filename in a workspace directory is a
+// taskfile. Hidden files hold metadata (donefiles, syncfiles, backups) and are
+// never lists themselves.
+func IsWorkspaceList(name string) bool {
+	return name != "" && !strings.HasPrefix(name, ".") && !strings.ContainsAny(name, `/\`)
+}
+
+// Sync merges the local lists with the synced ones and uploads the result,
+// retrying if the synced workspace changes in the meantime.
+func (ws *Workspace) Sync() error {
+	ws.tm.InitBackend()
+
+	for attempt := 1; ; attempt++ {
+		ok, err := ws.sync()
+
+		if err != nil || ok {
+			return err
+		}
+
+		if attempt == MaxUploadAttempts {
+			return NewSyncError(ErrSyncConflict, ws.tm.syncURL+ws.tm.syncID, attempt)
+		}
+
+		Warn("The synced workspace has changed since it was loaded. Merging and retrying.")
+	}
+}
+
+// sync performs a single merge and upload. Returns false if the upload failed
+// because the synced workspace has changed since it was fetched.
+func (ws *Workspace) sync() (bool, error) {
+	jsonData, etag, err := ws.tm.backend.Fetch(ws.tm.syncID)
+
+	var syncErr *SyncError
+
+	// Some backends (e.g.: git) only store a Sync ID once something has been
+	// uploaded, so it is missing until the first sync.
+	if errors.As(err, &syncErr) && syncErr.Code == ErrNoSyncID && ws.sf.ETag == "" {
+		jsonData, etag, err = nil, "", nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	remote := WorkspacePayload{Lists: make(map[string]SyncedList)}
+
+	if len(jsonData) != 0 {
+		if remote, err = ParseWorkspacePayload(ws.tm.decrypt(jsonData)); err != nil {
+			return false, NewSyncError(ErrInvalidPayload, ws.tm.syncID, err)
+		}
+	}
+
+	base := ws.loadBase()
+	local := ws.readLocal()
+
+	merged := MergeWorkspaces(base, local, remote, ConflictStrategy(ConfigOptions.OnConflict))
+
+	ws.writeLocal(local, merged)
+
+	if !sameWorkspace(merged, remote) {
+		mergedData, err := merged.Marshal()
+
+		if err != nil {
+			Error(ErrSerializeJSON, err)
+		}
+
+		etag, err = ws.tm.backend.Store(ws.tm.syncID, ws.tm.encrypt(mergedData), etag)
+
+		if errors.Is(err, errPreconditionFailed) {
+			return false, nil
+		}
+
+		if err != nil {
+			return false, err
+		}
+	}
+
+	ws.saveBase(merged, etag)
+
+	return true, nil
+}
+
+// ParseWorkspacePayload validates and decodes the data stored for a
+// workspace. Every list is validated like the payload of a single tasklist.
+// Empty data (e.g.: from a freshly created Sync ID) is an empty workspace;
+// workspaces without a version were written before the format was versioned.
+func ParseWorkspacePayload(jsonData []byte) (payload WorkspacePayload, err error) {
+	payload.Lists = make(map[string]SyncedList)
+
+	if len(strings.TrimSpace(string(jsonData))) == 0 {
+		return payload, nil
+	}
+
+	var keys map[string]json.RawMessage
+
+	if err := json.Unmarshal(jsonData, &keys); err != nil || keys == nil {
+		return payload, errors.New("not a JSON object")
+	}
+
+	var envelope workspaceEnvelope
+
+	if rawVersion, ok := keys["version"]; ok {
+		if _, err := parseVersion(rawVersion, WorkspacePayloadVersion); err != nil {
+			return payload, err
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(jsonData))
+		decoder.DisallowUnknownFields()
+
+		if err := decoder.Decode(&envelope); err != nil {
+			return payload, err
+		}
+	} else if err := json.Unmarshal(jsonData, &envelope); err != nil {
+		return payload, err
+	}
+
+	for name, raw := range envelope.Lists {
+		list, err := ParsePayload(raw)
+
+		if err != nil {
+			return payload, fmt.Errorf("list \"%s\": %v", name, err)
+		}
+
+		payload.Lists[name] = SyncedList{Contents: list.Contents, DoneContents: list.DoneContents}
+	}
+
+	return payload, nil
+}
+
+// Marshal encodes the workspace in the current format.
+func (p WorkspacePayload) Marshal() ([]byte, error) {
+	lists := make(map[string]Payload, len(p.Lists))
+
+	for name, list := range p.Lists {
+		lists[name] = Payload{Version: PayloadVersion, Contents: list.Contents, DoneContents: list.DoneContents}
+	}
+
+	return json.Marshal(struct {
+		Version int                `json:"version"`
+		Lists   map[string]Payload `json:"lists"`
+	}{WorkspacePayloadVersion, lists})
+}
+
+// MergeWorkspaces performs a three-way merge of the local and the remote
+// workspace using base as their common ancestor. Lists created or deleted on
+// one side are created or deleted in the result; lists changed on both sides
+// are merged task by task using MergeSnapshots. Changes to a list take
+// precedence over its deletion.
+func MergeWorkspaces(base, local, remote WorkspacePayload, strategy ConflictStrategy) (merged WorkspacePayload) {
+	merged.Lists = make(map[string]SyncedList)
+
+	names := make(map[string]bool)
+
+	for _, payload := range []WorkspacePayload{base, local, remote} {
+		for name := range payload.Lists {
+			names[name] = true
+		}
+	}
+
+	for name := range names {
+		b, inBase := base.Lists[name]
+		l, inLocal := local.Lists[name]
+		r, inRemote := remote.Lists[name]
+
+		switch {
+		case !inLocal && !inRemote:
+			continue
+		case !inLocal:
+			if !inBase || !sameList(b, r) {
+				merged.Lists[name] = r
+			}
+		case !inRemote:
+			if !inBase || !sameList(b, l) {
+				merged.Lists[name] = l
+			}
+		case sameList(l, r):
+			merged.Lists[name] = l
+		default:
+			source := "[workspace:" + name + "]"
+
+			result, conflicts := MergeSnapshots(
+				ParseSnapshot(source, b.Contents, b.DoneContents),
+				ParseSnapshot(source, l.Contents, l.DoneContents),
+				ParseSnapshot(source, r.Contents, r.DoneContents),
+				strategy,
+			)
+
+			if len(conflicts) > 0 {
+				Warn("%d task(s) in list \"%s\" were changed both locally and remotely, keeping the %s version.", len(conflicts), name, strategy)
+			}
+
+			contents, doneContents := result.Serialize()
+			merged.Lists[name] = SyncedList{Contents: contents, DoneContents: doneContents}
+		}
+	}
+
+	return
+}
+
+// sameList reports whether two lists hold the same tasks.
+func sameList(a SyncedList, b SyncedList) bool {
+	if a == b {
+		return true
+	}
+
+	return SameSnapshot(
+		ParseSnapshot("", a.Contents, a.DoneContents),
+		ParseSnapshot("", b.Contents, b.DoneContents),
+	)
+}
+
+// sameWorkspace reports whether two workspaces hold the same lists and tasks.
+func sameWorkspace(a WorkspacePayload, b WorkspacePayload) bool {
+	if len(a.Lists) != len(b.Lists) {
+		return false
+	}
+
+	for name, list := range a.Lists {
+		other, ok := b.Lists[name]
+
+		if !ok || !sameList(list, other) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// readLocal reads every list in the workspace directory.
+func (ws *Workspace) readLocal() (payload WorkspacePayload) {
+	payload.Lists = make(map[string]SyncedList)
+
+	entries, err := os.ReadDir(ws.dir)
+
+	if err != nil {
+		Error(ErrWorkspaceRead, ws.dir, err)
+	}
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !IsWorkspaceList(entry.Name()) {
+			continue
+		}
+
+		taskfilePath := filepath.Join(ws.dir, entry.Name())
+
+		contents, err := os.ReadFile(taskfilePath)
+
+		if err != nil {
+			Error(ErrTaskfileRead, taskfilePath, err)
+		}
+
+		doneContents, err := os.ReadFile(GetMetafilePath(".done", taskfilePath))
+
+		if err != nil && !os.IsNotExist(err) {
+			Error(ErrTaskfileRead, GetMetafilePath(".done", taskfilePath), err)
+		}
+
+		payload.Lists[entry.Name()] = SyncedList{Contents: string(contents), DoneContents: string(doneContents)}
+	}
+
+	return
+}
+
+// writeLocal updates the workspace directory to match the merged workspace,
+// creating, overwriting and deleting taskfiles as needed.
+func (ws *Workspace) writeLocal(local WorkspacePayload, merged WorkspacePayload) {
+	names := make([]string, 0, len(merged.Lists))
+
+	for name := range merged.Lists {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		list := merged.Lists[name]
+
+		if !IsWorkspaceList(name) {
+			Warn("Skipping synced list with invalid name \"%s\"", name)
+			continue
+		}
+
+		if current, ok := local.Lists[name]; ok && current == list {
+			continue
+		}
+
+		taskfilePath := filepath.Join(ws.dir, name)
+
+		ws.writeFile(taskfilePath, list.Contents)
+		ws.writeFile(GetMetafilePath(".done", taskfilePath), list.DoneContents)
+	}
+
+	for name := range local.Lists {
+		if _, ok := merged.Lists[name]; ok {
+			continue
+		}
+
+		taskfilePath := filepath.Join(ws.dir, name)
+
+		for _, path := range []string{taskfilePath, GetMetafilePath(".done", taskfilePath)} {
+			BackupTaskfile(path)
+
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				Error(ErrWorkspaceWrite, path, err)
+			}
+		}
+	}
+}
+
+// writeFile replaces a taskfile of the workspace, backing it up first like
+// Tasklist.SaveLocal does.
+func (ws *Workspace) writeFile(path string, contents string) {
+	BackupTaskfile(path)
+	writeFileAtomic(path, []byte(contents), ErrWorkspaceWrite)
+}
+
+// loadBase returns the last synced state of the workspace from its syncfile.
+func (ws *Workspace) loadBase() WorkspacePayload {
+	payload := WorkspacePayload{Lists: ws.sf.Ancestors}
+
+	if payload.Lists == nil {
+		payload.Lists = make(map[string]SyncedList)
+	}
+
+	return payload
+}
+
+// saveBase stores the merged workspace as the last synced state and writes
+// the syncfile.
+func (ws *Workspace) saveBase(payload WorkspacePayload, etag string) {
+	lastNetworkUpdate := Now()
+
+	ws.sf.ETag = etag
+	ws.sf.LastNetworkUpdate = &lastNetworkUpdate
+	ws.sf.markEncryptedUpload()
+	ws.sf.Ancestors = payload.Lists
+
+	ws.sf.Write(WorkspaceSyncfilePath(ws.dir))
+}
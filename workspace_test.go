@@ -0,0 +1,186 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMergeWorkspaces(t *testing.T) {
+	list := func(contents string) SyncedList {
+		return SyncedList{Contents: contents}
+	}
+
+	base := WorkspacePayload{Lists: map[string]SyncedList{
+		"edited":         list(mergeTaskA),
+		"deletedLocally": list(mergeTaskB),
+		"deletedChanged": list(mergeTaskC),
+	}}
+	local := WorkspacePayload{Lists: map[string]SyncedList{
+		"edited":         list(mergeTaskA + mergeTaskB),
+		"deletedChanged": list(mergeTaskC),
+		"addedLocally":   list(mergeTaskA),
+	}}
+	remote := WorkspacePayload{Lists: map[string]SyncedList{
+		"edited":         list(mergeTaskA + mergeTaskC),
+		"deletedLocally": list(mergeTaskB),
+		"deletedChanged": list(mergeTaskC + mergeTaskA),
+		"addedRemotely":  list(mergeTaskB),
+	}}
+
+	merged := MergeWorkspaces(base, local, remote, KeepLocal)
+
+	AssertEqual(t, len(merged.Lists), 4, "Unexpected number of merged lists")
+
+	for _, name := range []string{"edited", "deletedChanged", "addedLocally", "addedRemotely"} {
+		if _, ok := merged.Lists[name]; !ok {
+			t.Fatalf("List \"%s\" is missing from the merged workspace", name)
+		}
+	}
+
+	contents := merged.Lists["edited"].Contents
+
+	if !strings.Contains(contents, "B |") || !strings.Contains(contents, "C |") {
+		t.Fatalf("Changes to a list were not merged:\n%s", contents)
+	}
+}
+
+func TestParseWorkspacePayload(t *testing.T) {
+	t.Run("round_trip", func(t *testing.T) {
+		data, err := WorkspacePayload{Lists: map[string]SyncedList{"work": {Contents: mergeTaskA}}}.Marshal()
+		AssertEqual(t, err, nil, "Workspace could not be marshalled")
+
+		payload, err := ParseWorkspacePayload(data)
+
+		AssertEqual(t, err, nil, "Workspace was rejected")
+		AssertEqual(t, payload.Lists["work"].Contents, mergeTaskA, "List does not match")
+	})
+
+	t.Run("legacy", func(t *testing.T) {
+		payload, err := ParseWorkspacePayload([]byte(`{"lists": {"work": {"contents": "A\n", "doneContents": ""}}}`))
+
+		AssertEqual(t, err, nil, "Legacy workspace was rejected")
+		AssertEqual(t, payload.Lists["work"].Contents, "A\n", "List does not match")
+	})
+
+	invalid := map[string]string{
+		"not_object":    `["lists"]`,
+		"newer_version": `{"version": 2, "lists": {}}`,
+		"unknown_key":   `{"version": 1, "lists": {}, "other": 1}`,
+		"invalid_list":  `{"version": 1, "lists": {"work": {"contents": 1, "doneContents": ""}}}`,
+		"newer_list":    `{"version": 1, "lists": {"work": {"version": 2, "contents": "", "doneContents": ""}}}`,
+	}
+
+	for name, data := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseWorkspacePayload([]byte(data)); err == nil {
+				t.Fatalf("Invalid workspace was accepted: %s", data)
+			}
+		})
+	}
+}
+
+func TestWorkspaceSync(t *testing.T) {
+	ts := httptest.NewServer(NewSyncServer(t.TempDir(), true))
+	defer ts.Close()
+
+	first, second := t.TempDir(), t.TempDir()
+
+	os.WriteFile(filepath.Join(first, "work"), []byte(mergeTaskA), 0644)
+	os.WriteFile(filepath.Join(first, "home"), []byte(mergeTaskB), 0644)
+	os.WriteFile(filepath.Join(first, ".home.done"), []byte(mergeTaskC), 0644)
+
+	enableWorkspace(first, ts.URL+"/", "", nil)
+
+	syncID := LoadWorkspace(first).tm.syncID
+
+	// Join the workspace from another device.
+	enableWorkspace(second, ts.URL+"/", syncID, nil)
+
+	for _, name := range []string{"work", "home", ".home.done"} {
+		contents, err := os.ReadFile(filepath.Join(second, name))
+
+		AssertEqual(t, err, nil, "Synced list was not created: "+name)
+
+		if len(contents) == 0 {
+			t.Fatalf("Synced list \"%s\" is empty", name)
+		}
+	}
+
+	// Delete and create lists on the second device.
+	os.Remove(filepath.Join(second, "home"))
+	os.Remove(filepath.Join(second, ".home.done"))
+	os.WriteFile(filepath.Join(second, "errands"), []byte(mergeTaskC), 0644)
+
+	AssertEqual(t, LoadWorkspace(second).Sync(), nil, "Could not sync second workspace")
+
+	// Modify a list on the first device through the regular tasklist
+	// manager.
+	SyncfilePath = ""
+	InitPathVariables(filepath.Join(first, "work"))
+
+	MainList = &Tasklist{}
+	DoneList = &Tasklist{}
+	ListManager = &TasklistManager{}
+	ListManager.EnsureInitialized(MainList)
+
+	AssertNotEqual(t, ListManager.workspace, nil, "Workspace was not detected")
+
+	add("from the first device")
+	ListManager.Save()
+
+	_, err := os.Stat(filepath.Join(first, "home"))
+	AssertEqual(t, os.IsNotExist(err), true, "List deleted on another device still exists")
+
+	backup, _ := os.ReadFile(filepath.Join(first, ".home.bak"))
+	AssertEqual(t, string(backup), mergeTaskB, "Deleted list was not backed up")
+
+	_, err = os.Stat(filepath.Join(first, "errands"))
+	AssertEqual(t, err, nil, "List created on another device does not exist")
+
+	AssertEqual(t, LoadWorkspace(second).Sync(), nil, "Could not sync second workspace")
+
+	contents, _ := os.ReadFile(filepath.Join(second, "work"))
+
+	if !strings.Contains(string(contents), "from the first device |") {
+		t.Fatalf("Task added on the first device was not synced:\n%s", contents)
+	}
+}
+
+func TestGitWorkspaceSync(t *testing.T) {
+	syncURL := InitTestingGitRepo(t) + "/"
+
+	first, second := t.TempDir(), t.TempDir()
+
+	os.WriteFile(filepath.Join(first, "work"), []byte(mergeTaskA), 0644)
+
+	// The branch of a new Sync ID only exists once the first sync pushed it.
+	enableWorkspace(first, syncURL, "", nil)
+
+	syncID := LoadWorkspace(first).tm.syncID
+
+	enableWorkspace(second, syncURL, syncID, nil)
+
+	contents, err := os.ReadFile(filepath.Join(second, "work"))
+
+	AssertEqual(t, err, nil, "Synced list was not created")
+	AssertEqual(t, string(contents), mergeTaskA, "Synced list does not match")
+}
+
+func TestFailedWorkspaceEnable(t *testing.T) {
+	if os.Getenv("TX_TESTING") != "true" {
+		t.Setenv("TX_TESTING_WORKSPACE", t.TempDir())
+	}
+
+	dir := os.Getenv("TX_TESTING_WORKSPACE")
+	syncURL := "git+file://" + filepath.Join(dir, "missing.git") + "/"
+
+	AssertExitError(t, "TestFailedWorkspaceEnable", ErrGitCommand, func() {
+		enableWorkspace(dir, syncURL, "3fa9", nil)
+	})
+
+	_, err := os.Stat(WorkspaceSyncfilePath(dir))
+	AssertEqual(t, os.IsNotExist(err), true, "Syncfile was written although enabling failed")
+}